@@ -0,0 +1,156 @@
+package porto
+
+import (
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the package clauses whose vanity import differs from porto's along
+// with the fix, so porto can run with other linters e.g. through multichecker.
+var Analyzer = NewAnalyzer(Options{})
+
+// NewAnalyzer returns an analyzer reporting the package clauses whose vanity import
+// differs from porto's. Out of the options, it honors the ones about removing vanity
+// imports, skipping files, internal packages, single file per package, vanity paths and
+// the style of the vanity imports.
+func NewAnalyzer(opts Options) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name: toolName,
+		Doc:  "check the vanity import of package clauses\n\nReports the files whose vanity import is missing or differs from the import path of the package.",
+		URL:  toolURI,
+		Run: func(pass *analysis.Pass) (any, error) {
+			return runAnalyzer(pass, opts)
+		},
+	}
+}
+
+func runAnalyzer(pass *analysis.Pass, opts Options) (any, error) {
+	packageName, importPath := pass.Pkg.Name(), pass.Pkg.Path()
+	if packageName == "main" || strings.HasSuffix(packageName, "_test") || isUnexportedModule(importPath, opts.IncludeInternal) {
+		return nil, nil
+	}
+	if pass.Module != nil && importPath != pass.Module.Path && !strings.HasPrefix(importPath, pass.Module.Path+"/") {
+		// e.g. vendored packages, their vanity import belongs to another module
+		return nil, nil
+	}
+	moduleName := opts.vanityImportPath(importPath)
+
+	var (
+		files    []packageFile
+		astFiles = map[string]*ast.File{}
+	)
+	for _, pf := range pass.Files {
+		tokenFile := pass.Fset.File(pf.Pos())
+		fileName := filepath.Base(tokenFile.Name())
+		if fileName == "doc.go" && hasIgnoreDirective(pf) {
+			// the whole package opted out
+			return nil, nil
+		}
+		if fileSkipReason(opts, fileName) != "" || isGeneratedFile(pf, tokenFile) || hasIgnoreDirective(pf) {
+			continue
+		}
+
+		files = append(files, packageFile{
+			name:        fileName,
+			absFilepath: tokenFile.Name(),
+			clause:      newPackageClause(pass.Fset, pf),
+		})
+		astFiles[tokenFile.Name()] = pf
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	importPaths := map[string]struct{}{}
+	for _, f := range files {
+		if f.clause.importComment != "" {
+			importPaths[importPathFromComment(f.clause.importComment)] = struct{}{}
+		}
+	}
+
+	readFile := pass.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+
+	canonical := canonicalFile(packageName, files)
+	for _, f := range files {
+		if opts.SingleFilePerPackage && !opts.RemoveVanityImport &&
+			f.clause.importComment == "" && (len(importPaths) > 0 || f.name != canonical) {
+			continue
+		}
+
+		if err := reportFile(pass, opts, moduleName, f, astFiles[f.absFilepath], readFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// reportFile reports the diagnostic of a file whose vanity import differs along with the
+// fix to apply.
+func reportFile(pass *analysis.Pass, opts Options, moduleName string, f packageFile, pf *ast.File, readFile func(string) ([]byte, error)) error {
+	content, err := readFile(f.absFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to read the file %q: %v", f.absFilepath, err)
+	}
+
+	var (
+		hasChanged bool
+		newContent []byte
+	)
+	e := Event{ModulePath: moduleName, OldImportComment: f.clause.importComment}
+	if opts.RemoveVanityImport {
+		hasChanged, newContent, _, err = removeImportPathFromContent(f.absFilepath, content)
+	} else {
+		hasChanged, newContent, _, e.NewImportComment, err = addImportPathToContent(f.absFilepath, content, moduleName, opts.ImportCommentStyle)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add vanity import path to %q: %v", f.absFilepath, err)
+	}
+	if !hasChanged {
+		return nil
+	}
+
+	fixMessage := fmt.Sprintf("Set vanity import to %q", moduleName)
+	if opts.RemoveVanityImport {
+		fixMessage = "Remove vanity import"
+	}
+
+	tokenFile := pass.Fset.File(pf.Pos())
+	start, end, newText := textEdit(content, newContent)
+	pass.Report(analysis.Diagnostic{
+		Pos:      pf.Package,
+		End:      pf.Name.End(),
+		Category: vanityRule,
+		Message:  findingMessage(e),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   fixMessage,
+			TextEdits: []analysis.TextEdit{{Pos: tokenFile.Pos(start), End: tokenFile.Pos(end), NewText: newText}},
+		}},
+	})
+	return nil
+}
+
+// textEdit returns the offsets of the range of the original content to be replaced
+// along with the new text to turn it into the new content.
+func textEdit(original, content []byte) (int, int, []byte) {
+	start := 0
+	for start < len(original) && start < len(content) && original[start] == content[start] {
+		start++
+	}
+
+	end := 0
+	for end < len(original)-start && end < len(content)-start &&
+		original[len(original)-1-end] == content[len(content)-1-end] {
+		end++
+	}
+
+	return start, len(original) - end, content[start : len(content)-end]
+}
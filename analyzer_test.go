@@ -0,0 +1,152 @@
+package porto
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis"
+)
+
+// runTestAnalyzer runs the analyzer over a package made of the given files, returning
+// the diagnostics by file name along with the file set they refer to.
+func runTestAnalyzer(
+	t *testing.T, a *analysis.Analyzer, importPath string, module *analysis.Module, files map[string]string,
+) (map[string]analysis.Diagnostic, *token.FileSet) {
+	dir := t.TempDir()
+	fset := token.NewFileSet()
+
+	var (
+		astFiles    []*ast.File
+		packageName string
+	)
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(dir+"/"+name, []byte(content), 0644))
+		pf, err := parser.ParseFile(fset, dir+"/"+name, content, parser.ParseComments)
+		require.NoError(t, err)
+		astFiles = append(astFiles, pf)
+		packageName = pf.Name.Name
+	}
+
+	diagnostics := map[string]analysis.Diagnostic{}
+	pass := &analysis.Pass{
+		Analyzer: a,
+		Fset:     fset,
+		Files:    astFiles,
+		Pkg:      types.NewPackage(importPath, packageName),
+		Module:   module,
+		ReadFile: os.ReadFile,
+		Report: func(d analysis.Diagnostic) {
+			tokenFile := fset.File(d.Pos)
+			diagnostics[tokenFile.Name()[len(dir)+1:]] = d
+		},
+	}
+
+	_, err := a.Run(pass)
+	require.NoError(t, err)
+	return diagnostics, fset
+}
+
+// applyFix applies the text edits of the suggested fix of a diagnostic to the content.
+func applyFix(t *testing.T, fset *token.FileSet, content string, d analysis.Diagnostic) string {
+	require.Len(t, d.SuggestedFixes, 1)
+	require.Len(t, d.SuggestedFixes[0].TextEdits, 1)
+
+	edit := d.SuggestedFixes[0].TextEdits[0]
+	start, end := fset.Position(edit.Pos).Offset, fset.Position(edit.End).Offset
+	return content[:start] + string(edit.NewText) + content[end:]
+}
+
+func TestAnalyzer(t *testing.T) {
+	t.Run("reports and fixes", func(t *testing.T) {
+		files := map[string]string{
+			"pad.go":      "package pad\n\nvar A = 1\n",
+			"wrong.go":    "package pad // import \"example.com/old/pad\"\n",
+			"right.go":    "package pad // import \"example.com/repo/pad\"\n",
+			"pad_test.go": "package pad\n",
+			"gen.go":      "// Code generated by gen. DO NOT EDIT.\n\npackage pad\n",
+			"legacy.go":   "//porto:ignore\n\npackage pad\n",
+		}
+		diagnostics, fset := runTestAnalyzer(t, Analyzer, "example.com/repo/pad", &analysis.Module{Path: "example.com/repo"}, files)
+		require.Len(t, diagnostics, 2)
+
+		d := diagnostics["pad.go"]
+		assert.Equal(t, "missing vanity import \"example.com/repo/pad\"", d.Message)
+		assert.Equal(t, 1, fset.Position(d.Pos).Line)
+		assert.Equal(t, "package pad // import \"example.com/repo/pad\"\n\nvar A = 1\n", applyFix(t, fset, files["pad.go"], d))
+
+		d = diagnostics["wrong.go"]
+		assert.Equal(t, "wrong vanity import \"example.com/old/pad\", expected \"example.com/repo/pad\"", d.Message)
+		assert.Equal(t, "package pad // import \"example.com/repo/pad\"\n", applyFix(t, fset, files["wrong.go"], d))
+	})
+
+	t.Run("skipped packages", func(t *testing.T) {
+		module := &analysis.Module{Path: "example.com/repo"}
+		for importPath, files := range map[string]map[string]string{
+			"example.com/repo/cmd":          {"main.go": "package main\n"},
+			"example.com/repo/pad_test":     {"pad_test.go": "package pad_test\n"},
+			"example.com/repo/internal/pad": {"pad.go": "package pad\n"},
+			"example.com/other/pad":         {"pad.go": "package pad\n"},
+			"example.com/repo/ignored":      {"doc.go": "//porto:ignore\n\npackage ignored\n", "ignored.go": "package ignored\n"},
+		} {
+			diagnostics, _ := runTestAnalyzer(t, Analyzer, importPath, module, files)
+			assert.Empty(t, diagnostics, importPath)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		a := NewAnalyzer(Options{
+			SingleFilePerPackage: true,
+			SkipFilesRegexes:     []*regexp.Regexp{regexp.MustCompile(`\.pb\.go$`)},
+			VanityPaths:          map[string]string{"example.com/repo": "go.example.dev"},
+		})
+
+		diagnostics, _ := runTestAnalyzer(t, a, "example.com/repo/pad", nil, map[string]string{
+			"doc.go":    "package pad\n",
+			"pad.go":    "package pad\n",
+			"pad.pb.go": "package pad\n",
+		})
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "missing vanity import \"go.example.dev/pad\"", diagnostics["doc.go"].Message)
+
+		a = NewAnalyzer(Options{RemoveVanityImport: true})
+		diagnostics, _ = runTestAnalyzer(t, a, "example.com/repo/pad", nil, map[string]string{
+			"pad.go":   "package pad // import \"example.com/repo/pad\"\n",
+			"other.go": "package pad\n",
+		})
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "unexpected vanity import \"example.com/repo/pad\"", diagnostics["pad.go"].Message)
+		assert.Equal(t, "Remove vanity import", diagnostics["pad.go"].SuggestedFixes[0].Message)
+
+		a = NewAnalyzer(Options{ImportCommentStyle: ImportCommentBlock})
+		diagnostics, _ = runTestAnalyzer(t, a, "example.com/repo/pad", nil, map[string]string{
+			"pad.go":   "package pad // import \"example.com/repo/pad\"\n",
+			"other.go": "package pad /* import \"example.com/repo/pad\" */\n",
+		})
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "vanity import \"example.com/repo/pad\" not written as expected", diagnostics["pad.go"].Message)
+	})
+}
+
+func TestTextEdit(t *testing.T) {
+	start, end, newText := textEdit([]byte("package pad\n\nvar a\n"), []byte("package pad // import \"pad\"\n\nvar a\n"))
+	assert.Equal(t, 11, start)
+	assert.Equal(t, 11, end)
+	assert.Equal(t, " // import \"pad\"", string(newText))
+
+	start, end, newText = textEdit([]byte("package pad // import \"a\"\n"), []byte("package pad // import \"b\"\n"))
+	assert.Equal(t, 23, start)
+	assert.Equal(t, 24, end)
+	assert.Equal(t, "b", string(newText))
+
+	start, end, newText = textEdit([]byte("package pad\n"), []byte("package pad\n"))
+	assert.Equal(t, 12, start)
+	assert.Equal(t, 12, end)
+	assert.Empty(t, newText)
+}
@@ -0,0 +1,180 @@
+package porto
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ignoreTag is the build tag used by convention to exclude a file from the build,
+// e.g. for scratch programs run with go run living in library directories.
+const ignoreTag = "ignore"
+
+// maxConstraintTags caps the number of tags in a build constraint we are willing
+// to evaluate exhaustively.
+const maxConstraintTags = 16
+
+// readBuildConstraint returns the build constraint of a go file, nil if it has none.
+// The file is read from disk unless its content is given.
+func readBuildConstraint(absFilepath string, content []byte) (constraint.Expr, error) {
+	var src any
+	if content != nil {
+		src = content
+	}
+
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, absFilepath, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the file %q: %v", absFilepath, err)
+	}
+
+	var plusBuildExprs []constraint.Expr
+	for _, commentGroup := range pf.Comments {
+		if commentGroup.End() >= pf.Package {
+			break
+		}
+
+		for _, comment := range commentGroup.List {
+			if constraint.IsGoBuild(comment.Text) {
+				// //go:build takes precedence over // +build lines
+				expr, err := constraint.Parse(comment.Text)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse the build constraint of %q: %v", absFilepath, err)
+				}
+				return expr, nil
+			}
+
+			if constraint.IsPlusBuild(comment.Text) {
+				expr, err := constraint.Parse(comment.Text)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse the build constraint of %q: %v", absFilepath, err)
+				}
+				plusBuildExprs = append(plusBuildExprs, expr)
+			}
+		}
+	}
+
+	var expr constraint.Expr
+	for _, e := range plusBuildExprs {
+		if expr == nil {
+			expr = e
+		} else {
+			expr = &constraint.AndExpr{X: expr, Y: e}
+		}
+	}
+	return expr, nil
+}
+
+// requiresIgnoreTag tells whether a build constraint can only be satisfied when
+// passing the ignore tag.
+func requiresIgnoreTag(expr constraint.Expr) bool {
+	if expr == nil {
+		return false
+	}
+
+	hasIgnoreTag := false
+	tagIndexes := map[string]int{}
+	// evaluating with every tag unset visits all of them as there is no short circuit
+	expr.Eval(func(tag string) bool {
+		if tag == ignoreTag {
+			hasIgnoreTag = true
+		} else if _, ok := tagIndexes[tag]; !ok {
+			tagIndexes[tag] = len(tagIndexes)
+		}
+		return false
+	})
+	if !hasIgnoreTag || len(tagIndexes) > maxConstraintTags {
+		return false
+	}
+
+	// we look for any combination of the other tags satisfying the constraint
+	for mask := 0; mask < 1<<len(tagIndexes); mask++ {
+		if expr.Eval(func(tag string) bool {
+			if tag == ignoreTag {
+				return false
+			}
+			return mask&(1<<tagIndexes[tag]) != 0
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// buildSkipReason returns the reason for skipping a go file based on its build
+// constraints and file name suffixes, empty if it should be evaluated. The file is
+// read from disk unless its content is given or walking a file system.
+func buildSkipReason(opts Options, absDir, fileName string, content []byte) (SkipReason, error) {
+	if content == nil && opts.fsys != nil && (opts.SkipIgnoredFiles || opts.BuildContext != nil) {
+		var err error
+		if content, err = opts.readFile(absDir + pathSeparator + fileName); err != nil {
+			return "", fmt.Errorf("failed to read the file %q: %v", absDir+pathSeparator+fileName, err)
+		}
+	}
+
+	if opts.SkipIgnoredFiles {
+		expr, err := readBuildConstraint(absDir+pathSeparator+fileName, content)
+		if err != nil {
+			return "", err
+		}
+
+		if requiresIgnoreTag(expr) {
+			return SkipIgnoredFile, nil
+		}
+	}
+
+	if opts.BuildContext != nil {
+		ctx := opts.BuildContext
+		if content != nil {
+			ctx = contentBuildContext(ctx, absDir+pathSeparator+fileName, content)
+		}
+
+		match, err := ctx.MatchFile(absDir, fileName)
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate build constraints of %q: %v", absDir+pathSeparator+fileName, err)
+		}
+
+		if !match {
+			return SkipBuildConstraints, nil
+		}
+	}
+
+	return "", nil
+}
+
+// contentBuildContext returns a copy of the build context reading the given file
+// from its content rather than from disk.
+func contentBuildContext(ctx *build.Context, absFilepath string, content []byte) *build.Context {
+	c := *ctx
+	c.OpenFile = func(path string) (io.ReadCloser, error) {
+		if filepath.Clean(path) == filepath.Clean(absFilepath) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		}
+		if ctx.OpenFile != nil {
+			return ctx.OpenFile(path)
+		}
+		return os.Open(path)
+	}
+	return &c
+}
+
+// NewBuildContext returns the build context for the given GOOS, GOARCH and build
+// tags, using the ones of the current platform when empty.
+func NewBuildContext(goos, goarch string, tags []string) *build.Context {
+	ctx := build.Default
+	if goos != "" {
+		ctx.GOOS = goos
+	}
+	if goarch != "" {
+		ctx.GOARCH = goarch
+	}
+	ctx.BuildTags = tags
+	return &ctx
+}
@@ -0,0 +1,99 @@
+package porto
+
+import (
+	"go/build/constraint"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBuildConstraint(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/none.go", []byte("// a comment\n\npackage pad\n"), 0644))
+	require.NoError(t, os.WriteFile(dir+"/gobuild.go", []byte("//go:build ignore\n// +build linux\n\npackage pad\n"), 0644))
+	require.NoError(t, os.WriteFile(dir+"/plusbuild.go", []byte("// +build linux\n// +build amd64\n\npackage pad\n"), 0644))
+
+	expr, err := readBuildConstraint(dir+"/none.go", nil)
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+
+	expr, err = readBuildConstraint(dir+"/gobuild.go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ignore", expr.String())
+
+	expr, err = readBuildConstraint(dir+"/plusbuild.go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "linux && amd64", expr.String())
+}
+
+func TestRequiresIgnoreTag(t *testing.T) {
+	tests := map[string]bool{
+		"//go:build ignore":              true,
+		"//go:build ignore && linux":     true,
+		"//go:build ignore || linux":     false,
+		"//go:build !windows":            false,
+		"//go:build ignored":             false,
+		"//go:build (ignore || a) && !a": true,
+	}
+
+	for line, expected := range tests {
+		t.Run(line, func(t *testing.T) {
+			expr, err := constraint.Parse(line)
+			require.NoError(t, err)
+			assert.Equal(t, expected, requiresIgnoreTag(expr))
+		})
+	}
+
+	assert.False(t, requiresIgnoreTag(nil))
+}
+
+func TestBuildSkipReason(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/pad.go", []byte("package pad\n"), 0644))
+	require.NoError(t, os.WriteFile(dir+"/pad_windows.go", []byte("package pad\n"), 0644))
+	require.NoError(t, os.WriteFile(dir+"/gen.go", []byte("//go:build ignore\n\npackage main\n"), 0644))
+	require.NoError(t, os.WriteFile(dir+"/tagged.go", []byte("//go:build pad\n\npackage pad\n"), 0644))
+
+	tests := []struct {
+		name     string
+		opts     Options
+		fileName string
+		expected SkipReason
+	}{
+		{name: "no build context", fileName: "pad_windows.go"},
+		{name: "no build context with ignored file", fileName: "gen.go"},
+		{name: "skip ignored files", opts: Options{SkipIgnoredFiles: true}, fileName: "gen.go", expected: SkipIgnoredFile},
+		{name: "matching file", opts: Options{BuildContext: NewBuildContext("linux", "amd64", nil)}, fileName: "pad.go"},
+		{name: "GOOS suffix", opts: Options{BuildContext: NewBuildContext("linux", "amd64", nil)}, fileName: "pad_windows.go", expected: SkipBuildConstraints},
+		{name: "GOOS suffix matching", opts: Options{BuildContext: NewBuildContext("windows", "amd64", nil)}, fileName: "pad_windows.go"},
+		{name: "missing tag", opts: Options{BuildContext: NewBuildContext("linux", "amd64", nil)}, fileName: "tagged.go", expected: SkipBuildConstraints},
+		{name: "tag", opts: Options{BuildContext: NewBuildContext("linux", "amd64", []string{"pad"})}, fileName: "tagged.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := buildSkipReason(tt.opts, dir, tt.fileName, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+func TestBuildSkipReasonForContent(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("//go:build ignore\n\npackage pad\n")
+
+	reason, err := buildSkipReason(Options{SkipIgnoredFiles: true}, dir, "unsaved.go", content)
+	require.NoError(t, err)
+	assert.Equal(t, SkipIgnoredFile, reason)
+
+	reason, err = buildSkipReason(Options{BuildContext: NewBuildContext("linux", "amd64", nil)}, dir, "unsaved.go", content)
+	require.NoError(t, err)
+	assert.Equal(t, SkipBuildConstraints, reason)
+
+	reason, err = buildSkipReason(Options{BuildContext: NewBuildContext("linux", "amd64", []string{"ignore"})}, dir, "unsaved.go", content)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+}
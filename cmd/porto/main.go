@@ -1,43 +1,125 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strings"
 
 	"github.com/jcchavezs/porto"
 )
 
-func main() {
-	flagWriteOutputToFile := flag.Bool("w", false, "Write result to (source) file instead of stdout")
-	flagListDiff := flag.Bool("l", false, "List files whose vanity import differs from porto's")
-	flagSkipFiles := flag.String("skip-files", "", "Regexps of files to skip")
-	flagSkipDirs := flag.String("skip-dirs", "", "Regexps of directories to skip")
-	flagSkipDefaultDirs := flag.Bool("skip-dirs-use-default", true, "Use default skip directory list")
-	flagIncludeInternal := flag.Bool("include-internal", false, "Include internal folders")
-	flagRestrictToFiles := flag.String("restrict-to-files", "", "Regexps of files to restrict the inspection on. It takes precedence over -skip-files")
-	flagRestrictToDirs := flag.String("restrict-to-dirs", "", "Regexps of dirs to restrict the inspection on. It takes precedence over -skip-dirs")
+// cliFlags holds the flags porto is run with.
+type cliFlags struct {
+	writeOutputToFile      bool
+	listDiff               bool
+	displayDiff            bool
+	remove                 bool
+	skipFiles              string
+	skipDirs               string
+	skipDefaultDirs        bool
+	includeInternal        bool
+	restrictToFiles        string
+	restrictToDirs         string
+	vanityPaths            string
+	importCommentStyle     string
+	tags                   string
+	goos                   string
+	goarch                 string
+	skipIgnored            bool
+	singleFile             bool
+	failOnMultiplePackages bool
+	checkConflicts         bool
+	explain                bool
+	useWorkspace           bool
+	keepGoing              bool
+	changedSince           string
+	staged                 bool
+	concurrency            int
+	stdinPath              string
+	useConfigFiles         bool
+	format                 string
+	// names of the flags set in the command line
+	set map[string]bool
+}
+
+// parseFlags parses the command line flags.
+func parseFlags() *cliFlags {
+	f := &cliFlags{set: map[string]bool{}}
+	flag.BoolVar(&f.writeOutputToFile, "w", false, "Write result to (source) file instead of stdout")
+	flag.BoolVar(&f.listDiff, "l", false, "List files whose vanity import differs from porto's")
+	flag.BoolVar(&f.displayDiff, "d", false, "Display diffs instead of rewriting files")
+	flag.BoolVar(&f.remove, "remove", false, "Remove vanity imports instead of adding them")
+	flag.StringVar(&f.skipFiles, "skip-files", "", "Regexps of files to skip")
+	flag.StringVar(&f.skipDirs, "skip-dirs", "", "Regexps of directories to skip")
+	flag.BoolVar(&f.skipDefaultDirs, "skip-dirs-use-default", true, "Use default skip directory list")
+	flag.BoolVar(&f.includeInternal, "include-internal", false, "Include internal folders")
+	flag.StringVar(&f.restrictToFiles, "restrict-to-files", "", "Regexps of files to restrict the inspection on. It takes precedence over -skip-files")
+	flag.StringVar(&f.restrictToDirs, "restrict-to-dirs", "", "Regexps of dirs to restrict the inspection on. It takes precedence over -skip-dirs")
+	flag.StringVar(&f.vanityPaths, "vanity-paths", "",
+		"Comma-separated list of module=vanity path prefixes, for packages served under a vanity domain different from the module path")
+	flag.StringVar(&f.importCommentStyle, "import-comment-style", "",
+		"Style of the vanity imports written, one of: "+strings.Join(porto.ImportCommentStyles, ", ")+
+			". By default the style of the current vanity imports is kept, using line comments otherwise")
+	flag.StringVar(&f.tags, "tags", "", "Comma-separated list of build tags, files excluded by build constraints are skipped")
+	flag.StringVar(&f.goos, "goos", "", "GOOS used to evaluate build constraints, files excluded by them are skipped")
+	flag.StringVar(&f.goarch, "goarch", "", "GOARCH used to evaluate build constraints, files excluded by them are skipped")
+	flag.BoolVar(&f.skipIgnored, "skip-ignored", false, "Skip files only built with the ignore tag")
+	flag.BoolVar(&f.singleFile, "single-file-per-package", false, "Add the vanity import to a single file per package, preferring doc.go")
+	flag.BoolVar(&f.failOnMultiplePackages, "fail-on-multiple-packages", false, "Fail when a directory holds more than one package instead of skipping it")
+	flag.BoolVar(&f.checkConflicts, "check-conflicts", false, "Report files whose vanity import conflicts with other files in the same package")
+	flag.BoolVar(&f.explain, "explain", false, "Report also unchanged and skipped files along with the reason")
+	flag.BoolVar(&f.useWorkspace, "workspace", false, "Process exactly the modules listed in the go.work file found in the target path or any of its parents")
+	flag.BoolVar(&f.keepGoing, "keep-going", false, "Keep processing the rest of the files after a failure and report all errors at the end")
+	flag.StringVar(&f.changedSince, "changed-since", "", "Process only the go files changed in git since the given revision, including untracked ones")
+	flag.BoolVar(&f.staged, "staged", false, "Process only the go files staged in git")
+	flag.IntVar(&f.concurrency, "j", runtime.GOMAXPROCS(0), "Number of directories evaluated concurrently")
+	flag.StringVar(&f.stdinPath, "stdin-path", "", "Read the go source from stdin as if it were the given file and write the result to stdout")
+	flag.BoolVar(&f.useConfigFiles, "use-config-files", true,
+		"Apply the "+porto.ConfigFileName+" config files found from the repository root down to the walked directories")
+	flag.StringVar(&f.format, "format", porto.FormatText, "Output format, one of: "+strings.Join(porto.Formats, ", "))
 	flag.Parse()
 
-	baseDir := flag.Arg(0)
+	flag.Visit(func(fl *flag.Flag) {
+		f.set[fl.Name] = true
+	})
+	return f
+}
+
+func main() {
+	f := parseFlags()
 
-	if len(flag.Args()) == 0 {
+	if len(flag.Args()) == 0 && f.stdinPath == "" {
 		flag.Usage()
 		fmt.Println(`
 Examples:
 
 Add import path to a folder
     $ porto -w ./myproject
+
+Add import path to the packages matching some patterns
+    $ porto -w ./pkg/... ./cmd/foo
+
+Add import path to an editor buffer
+    $ porto -stdin-path ./myproject/pkg/file.go < buffer.go
 		`)
 		os.Exit(0)
 	}
 
-	baseAbsDir, err := filepath.Abs(baseDir)
-	if err != nil {
-		log.Fatalf("failed to resolve base absolute path for target path %q: %v", baseDir, err)
+	if f.stdinPath != "" {
+		// the result is always written to stdout, as gofmt does for stdin
+		for _, name := range []string{"l", "d", "w"} {
+			if f.set[name] {
+				log.Fatalf("cannot use -%s with -stdin-path", name)
+			}
+		}
 	}
 
 	workingDir, err := os.Getwd()
@@ -45,37 +127,161 @@ Add import path to a folder
 		log.Fatalf("failed to resolve base absolute path for current working dir: %v", err)
 	}
 
-	skipFilesRegex, err := porto.GetRegexpList(*flagSkipFiles)
+	if f.useConfigFiles {
+		if err := f.applyConfig(workingDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	reporter, err := porto.NewReporter(f.format, os.Stdout)
+	if err != nil {
+		log.Fatalf("failed to build the reporter: %v", err)
+	}
+
+	opts, err := f.options(workingDir, reporter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if f.stdinPath != "" {
+		if err := processStdin(f.stdinPath, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	diffCount, err := porto.FindAndAddVanityImportForPatterns(workingDir, flag.Args(), opts)
+	if err != nil && !f.keepGoing {
+		log.Fatal(err)
+	}
+
+	if flusher, ok := reporter.(porto.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			log.Fatalf("failed to write the report: %v", err)
+		}
+	}
+
+	var merr porto.MultiError
+	if errors.As(err, &merr) {
+		for _, err := range merr {
+			log.Println(err)
+		}
+		log.Fatalf("found %d errors, %d files with a different vanity import", len(merr), diffCount)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if f.listDiff && diffCount > 0 {
+		os.Exit(2)
+	}
+}
+
+// applyConfig applies the output format and mode of the config files found in the
+// working dir or its parents, unless set as flags.
+func (f *cliFlags) applyConfig(workingDir string) error {
+	config, err := porto.FindConfig(workingDir)
+	if err != nil {
+		return err
+	}
+
+	// flags take precedence over the config files
+	if config.Format != "" && !f.set["format"] {
+		f.format = config.Format
+	}
+	if !f.set["w"] && !f.set["l"] && !f.set["d"] {
+		switch config.Mode {
+		case porto.ModeWrite:
+			f.writeOutputToFile = true
+		case porto.ModeList:
+			f.listDiff = true
+		case porto.ModeDiff:
+			f.displayDiff = true
+		}
+	}
+	return nil
+}
+
+// options returns the options porto runs with according to the flags.
+func (f *cliFlags) options(workingDir string, reporter porto.Reporter) (porto.Options, error) {
+	opts := porto.Options{
+		WriteResultToFile:      f.writeOutputToFile,
+		ListDiffFiles:          f.listDiff,
+		DisplayDiff:            f.displayDiff,
+		RemoveVanityImport:     f.remove,
+		SkipIgnoredFiles:       f.skipIgnored,
+		SingleFilePerPackage:   f.singleFile,
+		FailOnMultiplePackages: f.failOnMultiplePackages,
+		CheckConflicts:         f.checkConflicts,
+		Explain:                f.explain,
+		UseWorkspace:           f.useWorkspace,
+		KeepGoing:              f.keepGoing,
+		Concurrency:            f.concurrency,
+		IncludeInternal:        f.includeInternal,
+		ImportCommentStyle:     f.importCommentStyle,
+		UseConfigFiles:         f.useConfigFiles,
+		Reporter:               reporter,
+	}
+
+	if err := f.setRegexes(&opts); err != nil {
+		return porto.Options{}, err
+	}
+
+	var err error
+	if opts.VanityPaths, err = parseVanityPaths(f.vanityPaths); err != nil {
+		return porto.Options{}, err
+	}
+
+	if opts.ConfigOverrides, err = f.configOverrides(); err != nil {
+		return porto.Options{}, err
+	}
+	opts.ConfigOverrides.VanityPaths = opts.VanityPaths
+
+	if f.changedSince != "" || f.staged {
+		changedFiles, err := porto.ChangedGoFiles(workingDir, f.changedSince, f.staged)
+		if err != nil {
+			return porto.Options{}, fmt.Errorf("failed to find the changed files: %v", err)
+		}
+		opts.ChangedFiles = changedFiles
+	}
+
+	if f.tags != "" || f.goos != "" || f.goarch != "" {
+		var tags []string
+		if f.tags != "" {
+			tags = strings.Split(f.tags, ",")
+		}
+		opts.BuildContext = porto.NewBuildContext(f.goos, f.goarch, tags)
+	}
+
+	return opts, nil
+}
+
+// setRegexes sets the regexes of the files and directories to skip or restrict to.
+func (f *cliFlags) setRegexes(opts *porto.Options) error {
+	skipFilesRegex, err := porto.GetRegexpList(f.skipFiles)
 	if err != nil {
-		log.Fatalf("failed to build files regexes to exclude: %v", err)
+		return fmt.Errorf("failed to build files regexes to exclude: %v", err)
 	}
 
-	restrictToFilesRegex, err := porto.GetRegexpList(*flagRestrictToFiles)
+	restrictToFilesRegex, err := porto.GetRegexpList(f.restrictToFiles)
 	if err != nil {
-		log.Fatalf("failed to build files regexes to include: %v", err)
+		return fmt.Errorf("failed to build files regexes to include: %v", err)
 	}
 
-	restrictToDirsRegex, err := porto.GetRegexpList(*flagRestrictToDirs)
+	restrictToDirsRegex, err := porto.GetRegexpList(f.restrictToDirs)
 	if err != nil {
-		log.Fatalf("failed to build dirs regexes to include: %v", err)
+		return fmt.Errorf("failed to build dirs regexes to include: %v", err)
 	}
 
 	var skipDirsRegex = []*regexp.Regexp{}
-	if *flagSkipDefaultDirs {
+	if f.skipDefaultDirs {
 		skipDirsRegex = append(skipDirsRegex, porto.StdExcludeDirRegexps...)
 	}
-	userSkipDirsRegex, err := porto.GetRegexpList(*flagSkipDirs)
+	userSkipDirsRegex, err := porto.GetRegexpList(f.skipDirs)
 	if err != nil {
-		log.Fatalf("failed to build directories regexes: %v", err)
+		return fmt.Errorf("failed to build directories regexes: %v", err)
 	}
 	skipDirsRegex = append(skipDirsRegex, userSkipDirsRegex...)
 
-	opts := porto.Options{
-		WriteResultToFile: *flagWriteOutputToFile,
-		ListDiffFiles:     *flagListDiff,
-		IncludeInternal:   *flagIncludeInternal,
-	}
-
 	if len(restrictToFilesRegex) > 0 {
 		opts.RestrictToFilesRegexes = restrictToFilesRegex
 	} else {
@@ -87,13 +293,84 @@ Add import path to a folder
 	} else {
 		opts.SkipDirsRegexes = skipDirsRegex
 	}
+	return nil
+}
 
-	diffCount, err := porto.FindAndAddVanityImportForDir(workingDir, baseAbsDir, opts)
+// configOverrides returns the settings given as flags, which take precedence over the
+// config files.
+func (f *cliFlags) configOverrides() (*porto.Config, error) {
+	c := &porto.Config{}
+	if f.set["skip-files"] {
+		c.SkipFiles = splitList(f.skipFiles)
+	}
+	if f.set["skip-dirs"] {
+		c.SkipDirs = splitList(f.skipDirs)
+	}
+	if f.set["skip-dirs-use-default"] {
+		c.SkipDirsUseDefault = &f.skipDefaultDirs
+	}
+	if f.set["restrict-to-files"] {
+		c.RestrictToFiles = splitList(f.restrictToFiles)
+	}
+	if f.set["restrict-to-dirs"] {
+		c.RestrictToDirs = splitList(f.restrictToDirs)
+	}
+	if f.set["include-internal"] {
+		c.IncludeInternal = &f.includeInternal
+	}
+	if f.set["import-comment-style"] {
+		if !slices.Contains(porto.ImportCommentStyles, f.importCommentStyle) {
+			return nil, fmt.Errorf("unknown import comment style %q, expected one of %s", f.importCommentStyle, strings.Join(porto.ImportCommentStyles, ", "))
+		}
+		c.ImportCommentStyle = f.importCommentStyle
+	}
+	return c, nil
+}
+
+// parseVanityPaths parses a comma separated list of module=vanity path prefixes given
+// as flag, nil if empty.
+func parseVanityPaths(list string) (map[string]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	vanityPaths := map[string]string{}
+	for _, mapping := range strings.Split(list, ",") {
+		modulePrefix, vanityPrefix, ok := strings.Cut(mapping, "=")
+		if !ok || modulePrefix == "" || vanityPrefix == "" {
+			return nil, fmt.Errorf("failed to parse vanity path %q, expected module=vanity", mapping)
+		}
+		vanityPaths[modulePrefix] = vanityPrefix
+	}
+	return vanityPaths, nil
+}
+
+// processStdin writes to stdout the go source read from stdin once its vanity import
+// is fixed as if it were the given file.
+func processStdin(path string, opts porto.Options) error {
+	absFilepath, err := filepath.Abs(path)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to resolve absolute path for %q: %v", path, err)
 	}
 
-	if *flagListDiff && diffCount > 0 {
-		os.Exit(2)
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %v", err)
+	}
+
+	newContent, err := porto.FindAndAddVanityImportForContent(absFilepath, content, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(newContent)
+	return err
+}
+
+// splitList splits a comma separated list given as flag.
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
 	}
+	return strings.Split(list, ",")
 }
@@ -0,0 +1,296 @@
+package porto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of the files porto reads its configuration from. They
+// are looked up from the root of the repository down to the walked directories, the
+// settings of each one overriding the ones of its parents for its subtree.
+const ConfigFileName = ".porto.yaml"
+
+// Modes porto can run in when set from a config file.
+const (
+	ModePreview = "preview"
+	ModeWrite   = "write"
+	ModeList    = "list"
+	ModeDiff    = "diff"
+)
+
+// Modes lists the modes porto can run in.
+var Modes = []string{ModePreview, ModeWrite, ModeList, ModeDiff}
+
+// Config holds the settings read from a config file, the ones left unset keep their
+// current value.
+type Config struct {
+	// Regexes of files to skip
+	SkipFiles []string `yaml:"skip-files"`
+	// Regexes of directories to skip
+	SkipDirs []string `yaml:"skip-dirs"`
+	// Whether to skip the default list of directories along with SkipDirs
+	SkipDirsUseDefault *bool `yaml:"skip-dirs-use-default"`
+	// Regexes of files to restrict the inspection on
+	RestrictToFiles []string `yaml:"restrict-to-files"`
+	// Regexes of directories to restrict the inspection on
+	RestrictToDirs []string `yaml:"restrict-to-dirs"`
+	// Include internal packages
+	IncludeInternal *bool `yaml:"include-internal"`
+	// Vanity import path prefixes by module path prefix
+	VanityPaths map[string]string `yaml:"vanity-paths"`
+	// Style of the vanity imports written, one of ImportCommentStyles
+	ImportCommentStyle string `yaml:"import-comment-style"`
+	// Output format, only read from the config files in the working dir or its parents
+	Format string `yaml:"format"`
+	// Mode porto runs in, only read from the config files in the working dir or its parents
+	Mode string `yaml:"mode"`
+}
+
+// merge returns the config with the settings of other overriding its own.
+func (c Config) merge(other Config) Config {
+	if other.SkipFiles != nil {
+		c.SkipFiles = other.SkipFiles
+	}
+	if other.SkipDirs != nil {
+		c.SkipDirs = other.SkipDirs
+	}
+	if other.SkipDirsUseDefault != nil {
+		c.SkipDirsUseDefault = other.SkipDirsUseDefault
+	}
+	if other.RestrictToFiles != nil {
+		c.RestrictToFiles = other.RestrictToFiles
+	}
+	if other.RestrictToDirs != nil {
+		c.RestrictToDirs = other.RestrictToDirs
+	}
+	if other.IncludeInternal != nil {
+		c.IncludeInternal = other.IncludeInternal
+	}
+	if other.VanityPaths != nil {
+		c.VanityPaths = mergeVanityPaths(c.VanityPaths, other.VanityPaths)
+	}
+	if other.ImportCommentStyle != "" {
+		c.ImportCommentStyle = other.ImportCommentStyle
+	}
+	if other.Format != "" {
+		c.Format = other.Format
+	}
+	if other.Mode != "" {
+		c.Mode = other.Mode
+	}
+	return c
+}
+
+// mergeVanityPaths returns the union of two vanity path mappings, the ones in other
+// taking precedence.
+func mergeVanityPaths(vanityPaths, other map[string]string) map[string]string {
+	merged := make(map[string]string, len(vanityPaths)+len(other))
+	maps.Copy(merged, vanityPaths)
+	maps.Copy(merged, other)
+	return merged
+}
+
+// ReadConfigFile reads the config file in the given path.
+func ReadConfigFile(path string) (Config, error) {
+	return Options{}.readConfigFile(path)
+}
+
+// readConfigFile reads the config file in the given path of the walked file system.
+func (o Options) readConfigFile(path string) (Config, error) {
+	content, err := o.readFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file %q: %v", path, err)
+	}
+
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("failed to parse config file %q: %v", path, err)
+	}
+
+	if c.Format != "" && !slices.Contains(Formats, c.Format) {
+		return Config{}, fmt.Errorf("failed to parse config file %q: unknown format %q, expected one of %s", path, c.Format, strings.Join(Formats, ", "))
+	}
+	if c.ImportCommentStyle != "" && !slices.Contains(ImportCommentStyles, c.ImportCommentStyle) {
+		return Config{}, fmt.Errorf("failed to parse config file %q: unknown import comment style %q, expected one of %s",
+			path, c.ImportCommentStyle, strings.Join(ImportCommentStyles, ", "))
+	}
+	if c.Mode != "" && !slices.Contains(Modes, c.Mode) {
+		return Config{}, fmt.Errorf("failed to parse config file %q: unknown mode %q, expected one of %s", path, c.Mode, strings.Join(Modes, ", "))
+	}
+
+	return c, nil
+}
+
+// readDirConfig reads the config file in a given directory, if any.
+func (o Options) readDirConfig(absDir string) (Config, bool, error) {
+	path := absDir + pathSeparator + ConfigFileName
+	if fi, err := o.stat(path); err != nil || fi.IsDir() {
+		return Config{}, false, nil
+	}
+
+	c, err := o.readConfigFile(path)
+	return c, true, err
+}
+
+// findConfigDirs returns the directories holding a config file from the root of the
+// repository, or the root of the filesystem if there is none, down to the given one.
+func (o Options) findConfigDirs(absDir string) []string {
+	var dirs []string
+	for dir := absDir; ; {
+		if fi, err := o.stat(dir + pathSeparator + ConfigFileName); err == nil && !fi.IsDir() {
+			dirs = append(dirs, dir)
+		}
+
+		if _, err := o.stat(dir + pathSeparator + ".git"); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	slices.Reverse(dirs)
+	return dirs
+}
+
+// FindConfig returns the settings applying to the given directory, merging the config
+// files found from the root of the repository down to it.
+func FindConfig(absDir string) (Config, error) {
+	return Options{}.findConfig(absDir)
+}
+
+// findConfig returns the settings applying to the given directory of the walked file
+// system.
+func (o Options) findConfig(absDir string) (Config, error) {
+	var c Config
+	for _, dir := range o.findConfigDirs(absDir) {
+		dirConfig, _, err := o.readDirConfig(dir)
+		if err != nil {
+			return Config{}, err
+		}
+		c = c.merge(dirConfig)
+	}
+	return c, nil
+}
+
+// withConfig returns the options once the settings of the given config, read from the
+// given directory, are applied, keeping the ones in ConfigOverrides.
+func (o Options) withConfig(c Config, absDir string) (Options, error) {
+	o.config = o.config.merge(c)
+	if c.SkipDirs != nil || c.SkipDirsUseDefault != nil {
+		o.skipDirsBaseDir = absDir
+	}
+	if c.RestrictToDirs != nil {
+		o.restrictToDirsBaseDir = absDir
+	}
+	if o.ConfigOverrides != nil {
+		o.config = o.config.merge(*o.ConfigOverrides)
+		// the directories given as flags are relative to the walked one
+		if o.ConfigOverrides.SkipDirs != nil || o.ConfigOverrides.SkipDirsUseDefault != nil {
+			o.skipDirsBaseDir = ""
+		}
+		if o.ConfigOverrides.RestrictToDirs != nil {
+			o.restrictToDirsBaseDir = ""
+		}
+	}
+
+	var err error
+	if o.config.SkipFiles != nil {
+		if o.SkipFilesRegexes, err = compileRegexps(o.config.SkipFiles); err != nil {
+			return o, err
+		}
+	}
+	if o.config.SkipDirs != nil || o.config.SkipDirsUseDefault != nil {
+		skipDirsRegexes := []*regexp.Regexp{}
+		if o.config.SkipDirsUseDefault == nil || *o.config.SkipDirsUseDefault {
+			skipDirsRegexes = append(skipDirsRegexes, StdExcludeDirRegexps...)
+		}
+
+		userSkipDirsRegexes, err := compileRegexps(o.config.SkipDirs)
+		if err != nil {
+			return o, err
+		}
+		o.SkipDirsRegexes = append(skipDirsRegexes, userSkipDirsRegexes...)
+	}
+	if o.config.RestrictToFiles != nil {
+		if o.RestrictToFilesRegexes, err = compileRegexps(o.config.RestrictToFiles); err != nil {
+			return o, err
+		}
+	}
+	if o.config.RestrictToDirs != nil {
+		if o.RestrictToDirsRegexes, err = compileRegexps(o.config.RestrictToDirs); err != nil {
+			return o, err
+		}
+	}
+	if o.config.IncludeInternal != nil {
+		o.IncludeInternal = *o.config.IncludeInternal
+	}
+	if o.config.VanityPaths != nil {
+		o.VanityPaths = mergeVanityPaths(o.VanityPaths, o.config.VanityPaths)
+	}
+	if o.config.ImportCommentStyle != "" {
+		o.ImportCommentStyle = o.config.ImportCommentStyle
+	}
+
+	return o, nil
+}
+
+// withDirConfig applies the config file in the given directory, if any, when using
+// config files.
+func (o Options) withDirConfig(absDir string) (Options, error) {
+	if !o.UseConfigFiles {
+		return o, nil
+	}
+
+	c, ok, err := o.readDirConfig(absDir)
+	if err != nil || !ok {
+		return o, err
+	}
+	return o.withConfig(c, absDir)
+}
+
+// withConfigsFor applies the config files found from the root of the repository down
+// to the given directory when using config files, and then ConfigOverrides whether or
+// not any config file was found.
+func (o Options) withConfigsFor(absDir string) (Options, error) {
+	if o.UseConfigFiles {
+		for _, dir := range o.findConfigDirs(absDir) {
+			c, _, err := o.readDirConfig(dir)
+			if err != nil {
+				return o, err
+			}
+			if o, err = o.withConfig(c, dir); err != nil {
+				return o, err
+			}
+		}
+	}
+
+	return o.withConfig(Config{}, "")
+}
+
+// compileRegexps compiles a list of regexes.
+func compileRegexps(regexps []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(regexps))
+	for _, rx := range regexps {
+		r, err := regexp.Compile(rx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex %q: %w", rx, err)
+		}
+		regexes = append(regexes, r)
+	}
+	return regexes, nil
+}
@@ -0,0 +1,204 @@
+package porto
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"valid.yaml":          "skip-files: ['\\.pb\\.go$']\nskip-dirs-use-default: false\ninclude-internal: true\nformat: json\nmode: list\n",
+		"empty.yaml":          "",
+		"unknown-field.yaml":  "skip-file: ['a']\n",
+		"unknown-format.yaml": "format: xml\n",
+		"unknown-mode.yaml":   "mode: fix\n",
+		"unknown-style.yaml":  "import-comment-style: inline\n",
+	})
+
+	c, err := ReadConfigFile(dir + "/valid.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`\.pb\.go$`}, c.SkipFiles)
+	assert.Nil(t, c.SkipDirs)
+	require.NotNil(t, c.SkipDirsUseDefault)
+	assert.False(t, *c.SkipDirsUseDefault)
+	require.NotNil(t, c.IncludeInternal)
+	assert.True(t, *c.IncludeInternal)
+	assert.Equal(t, FormatJSON, c.Format)
+	assert.Equal(t, ModeList, c.Mode)
+
+	c, err = ReadConfigFile(dir + "/empty.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, Config{}, c)
+
+	_, err = ReadConfigFile(dir + "/unknown-field.yaml")
+	assert.ErrorContains(t, err, "field skip-file not found")
+
+	_, err = ReadConfigFile(dir + "/unknown-format.yaml")
+	assert.ErrorContains(t, err, "unknown format \"xml\"")
+
+	_, err = ReadConfigFile(dir + "/unknown-mode.yaml")
+	assert.ErrorContains(t, err, "unknown mode \"fix\"")
+
+	_, err = ReadConfigFile(dir + "/unknown-style.yaml")
+	assert.ErrorContains(t, err, "unknown import comment style \"inline\"")
+}
+
+func TestFindConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".porto.yaml":              "format: sarif\n",
+		"repo/.git/HEAD":           "ref: refs/heads/main\n",
+		"repo/.porto.yaml":         "skip-files: ['a']\nmode: list\n",
+		"repo/pkg/.porto.yaml":     "skip-files: ['b']\nskip-dirs: ['c']\n",
+		"repo/pkg/sub/placeholder": "",
+	})
+
+	c, err := FindConfig(dir + "/repo/pkg/sub")
+	require.NoError(t, err)
+	assert.Equal(t, Config{SkipFiles: []string{"b"}, SkipDirs: []string{"c"}, Mode: ModeList}, c)
+
+	c, err = FindConfig(dir)
+	require.NoError(t, err)
+	assert.Equal(t, Config{Format: FormatSARIF}, c)
+}
+
+func TestFindAndAddVanityImportWithConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".git/HEAD":           "ref: refs/heads/main\n",
+		".porto.yaml":         "skip-dirs: ['^tools$']\n",
+		"go.mod":              "module example.com/repo\n",
+		"repo.go":             "package repo\n",
+		"tools/tools.go":      "package tools\n",
+		"pkg/.porto.yaml":     "skip-files: ['\\.pb\\.go$']\ninclude-internal: true\n",
+		"pkg/pkg.go":          "package pkg\n",
+		"pkg/pkg.pb.go":       "package pkg\n",
+		"pkg/internal/in.go":  "package internal\n",
+		"other/other.pb.go":   "package other\n",
+		"other/internal/i.go": "package internal\n",
+	})
+
+	paths := func(events []Event) []string {
+		var paths []string
+		for _, e := range events {
+			paths = append(paths, e.Path)
+		}
+		return paths
+	}
+
+	t.Run("nested config files override their parents", func(t *testing.T) {
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, UseConfigFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo.go", "other/other.pb.go", "pkg/pkg.go", "pkg/internal/in.go"}, paths(r.events))
+	})
+
+	t.Run("config files of the parents apply to the target", func(t *testing.T) {
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForDir(dir, dir+"/pkg", Options{ListDiffFiles: true, UseConfigFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pkg/pkg.go", "pkg/internal/in.go"}, paths(r.events))
+	})
+
+	t.Run("overrides take precedence", func(t *testing.T) {
+		includeInternal := false
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForDir(dir, dir, Options{
+			ListDiffFiles:   true,
+			UseConfigFiles:  true,
+			ConfigOverrides: &Config{SkipDirs: []string{}, IncludeInternal: &includeInternal},
+			Reporter:        r,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo.go", "other/other.pb.go", "pkg/pkg.go", "tools/tools.go"}, paths(r.events))
+	})
+
+	t.Run("config files are ignored unless used", func(t *testing.T) {
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo.go", "other/other.pb.go", "pkg/pkg.go", "pkg/pkg.pb.go", "tools/tools.go"}, paths(r.events))
+	})
+
+	t.Run("directory regexes are relative to their config file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			".git/HEAD":          "ref: refs/heads/main\n",
+			"go.mod":             "module example.com/repo\n",
+			"gen/gen.go":         "package gen\n",
+			"sub/.porto.yaml":    "skip-dirs: ['^gen$']\n",
+			"sub/sub.go":         "package sub\n",
+			"sub/gen/g.go":       "package gen\n",
+			"sub/gen/x/x.go":     "package x\n",
+			"lib/.porto.yaml":    "restrict-to-dirs: ['^pkg']\n",
+			"lib/pkg/pkg.go":     "package pkg\n",
+			"lib/other/o.go":     "package other\n",
+			"lib/other/pkg/p.go": "package pkg\n",
+		})
+
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, UseConfigFiles: true, Reporter: r})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gen/gen.go", "lib/pkg/pkg.go", "sub/sub.go"}, paths(r.events))
+
+		r = &recordingReporter{}
+		_, err = FindAndAddVanityImportForDir(dir, dir+"/sub", Options{ListDiffFiles: true, UseConfigFiles: true, Reporter: r})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"sub/sub.go"}, paths(r.events))
+
+		r = &recordingReporter{}
+		_, err = FindAndAddVanityImportForDir(dir, dir+"/sub/gen/x", Options{ListDiffFiles: true, UseConfigFiles: true, Reporter: r})
+		require.NoError(t, err)
+		assert.Empty(t, r.events)
+	})
+
+	t.Run("overrides apply with or without config files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			".git/HEAD":      "ref: refs/heads/main\n",
+			"go.mod":         "module example.com/repo\n",
+			"repo.go":        "package repo\n",
+			"cmd/cmd.go":     "package cmd\n",
+			"pkg/pkg.go":     "package pkg\n",
+			"tools/tools.go": "package tools\n",
+		})
+
+		run := func() []string {
+			r := &recordingReporter{}
+			_, err := FindAndAddVanityImportForDir(dir, dir, Options{
+				ListDiffFiles:   true,
+				UseConfigFiles:  true,
+				ConfigOverrides: &Config{RestrictToDirs: []string{"^cmd"}},
+				Reporter:        r,
+			})
+			require.NoError(t, err)
+			return paths(r.events)
+		}
+
+		withoutConfigFile := run()
+		writeFiles(t, dir, map[string]string{".porto.yaml": "format: text\n"})
+		assert.Equal(t, []string{"repo.go", "cmd/cmd.go"}, withoutConfigFile)
+		assert.Equal(t, withoutConfigFile, run())
+	})
+
+	t.Run("invalid config file", func(t *testing.T) {
+		writeFiles(t, dir, map[string]string{"tools/.porto.yaml": "skip-files: ['(']\n"})
+		defer os.Remove(dir + "/tools/.porto.yaml")
+
+		_, err := FindAndAddVanityImportForDir(dir, dir, Options{
+			ListDiffFiles:   true,
+			UseConfigFiles:  true,
+			ConfigOverrides: &Config{SkipDirs: []string{}},
+			Reporter:        &recordingReporter{},
+		})
+		assert.ErrorContains(t, err, "failed to compile regex \"(\"")
+	})
+}
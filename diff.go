@@ -0,0 +1,42 @@
+package porto
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const noNewlineMarker = "\n\\ No newline at end of file\n"
+
+// splitLines splits the content in lines keeping the line endings so the diff
+// can be applied as is. A last line without newline gets the marker used by
+// diff and patch to tell them apart.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + noNewlineMarker
+	}
+
+	return lines
+}
+
+// unifiedDiff returns the unified diff between the original and the new content
+// of a file, using the a/ and b/ prefixes so it can be piped into `git apply` or
+// `patch -p1`.
+func unifiedDiff(path string, original, content []byte) (string, error) {
+	path = filepath.ToSlash(path)
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(original),
+		B:        splitLines(content),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+}
@@ -0,0 +1,52 @@
+package porto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLines(t *testing.T) {
+	assert.Nil(t, splitLines(nil))
+	assert.Equal(t, []string{"a\n", "b\n"}, splitLines([]byte("a\nb\n")))
+	assert.Equal(t, []string{"a\n", "b" + noNewlineMarker}, splitLines([]byte("a\nb")))
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("trailing newline", func(t *testing.T) {
+		diff, err := unifiedDiff(
+			"rightpad/rightpad.go",
+			[]byte("package rightpad // import \"wrong\"\n\nfunc A() {}\n"),
+			[]byte("package rightpad // import \"right\"\n\nfunc A() {}\n"),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, `--- a/rightpad/rightpad.go
++++ b/rightpad/rightpad.go
+@@ -1,3 +1,3 @@
+-package rightpad // import "wrong"
++package rightpad // import "right"
+ 
+ func A() {}
+`, diff)
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		diff, err := unifiedDiff("doc.go", []byte("package more"), []byte("package more // import \"more\""))
+		require.NoError(t, err)
+		assert.Equal(t, `--- a/doc.go
++++ b/doc.go
+@@ -1 +1 @@
+-package more
+\ No newline at end of file
++package more // import "more"
+\ No newline at end of file
+`, diff)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		diff, err := unifiedDiff("doc.go", []byte("package more\n"), []byte("package more\n"))
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+}
@@ -0,0 +1,42 @@
+package porto
+
+import "strings"
+
+// MultiError holds all the errors found while processing the files when keeping
+// going after a failure.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors held, allowing errors.Is and errors.As to inspect them.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// joinErrors returns a MultiError with the given errors flattening any MultiError
+// among them, nil if there are no errors.
+func joinErrors(errs ...error) error {
+	var merr MultiError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if m, ok := err.(MultiError); ok {
+			merr = append(merr, m...)
+		} else {
+			merr = append(merr, err)
+		}
+	}
+
+	if len(merr) == 0 {
+		return nil
+	}
+	return merr
+}
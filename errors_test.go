@@ -0,0 +1,19 @@
+package porto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinErrors(t *testing.T) {
+	assert.Nil(t, joinErrors())
+	assert.Nil(t, joinErrors(nil, nil))
+
+	errA, errB, errC := errors.New("a"), errors.New("b"), errors.New("c")
+	err := joinErrors(errA, nil, MultiError{errB, errC})
+	assert.Equal(t, MultiError{errA, errB, errC}, err)
+	assert.EqualError(t, err, "a\nb\nc")
+	assert.ErrorIs(t, err, errC)
+}
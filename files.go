@@ -1,8 +1,13 @@
 package porto
 
 import (
-	"io/ioutil"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"golang.org/x/mod/modfile"
@@ -26,24 +31,165 @@ func isUnexportedDir(dirname string, includeInternal bool) bool {
 	return dirname == "testdata" || (!includeInternal && dirname == "internal")
 }
 
-// writeContentToFile writes the content in bytes to a given file.
-func writeContentToFile(absFilepath string, content []byte) error {
-	f, err := os.OpenFile(absFilepath, os.O_WRONLY|os.O_TRUNC, 0644)
+// errFileChanged is returned when a file changed between being read and written.
+var errFileChanged = errors.New("file changed since it was read")
+
+// writeContentToFile writes the content in bytes to a given file. The content is
+// written to a temporary file in the same directory which then replaces the original
+// one, preserving its permissions and, where possible, its ownership. Symlinks are
+// followed so the file they point to is the one replaced. It fails if the file no
+// longer holds the original content.
+func writeContentToFile(absFilepath string, original, content []byte) error {
+	absFilepath, err := filepath.EvalSymlinks(absFilepath)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(absFilepath)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.Write(content)
-	return err
+	current, err := os.ReadFile(absFilepath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, original) {
+		return fmt.Errorf("failed to write %q: %w", absFilepath, errFileChanged)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(absFilepath), "."+filepath.Base(absFilepath)+".porto-*")
+	if err != nil {
+		return err
+	}
+	tmpFilepath := f.Name()
+	defer os.Remove(tmpFilepath) // no-op once renamed
+
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	// chown goes first as it clears the setuid and setgid bits
+	if err = chown(f, fi); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Chmod(fi.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilepath, absFilepath)
 }
 
 // findGoModule finds a go.mod file in a given directory
 func findGoModule(dir string) (string, bool) {
-	content, err := ioutil.ReadFile(dir + pathSeparator + "go.mod")
+	return Options{}.findGoModule(dir)
+}
+
+// findGoModule finds a go.mod file in a given directory of the walked file system.
+func (o Options) findGoModule(dir string) (string, bool) {
+	content, err := o.readFile(dir + pathSeparator + "go.mod")
 	if err != nil {
 		return "", false
 	}
 
 	return modfile.ModulePath(content), true
 }
+
+// findOwningModule finds the module a directory belongs to by looking for a go.mod
+// file in it or any of its parents. It returns the directory and path of the module.
+func (o Options) findOwningModule(dir string) (string, string, bool) {
+	for {
+		if moduleName, ok := o.findGoModule(dir); ok {
+			return dir, moduleName, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// importPathOf returns the import path of a directory based on the module it belongs
+// to, along with the directory of the module. As for the go command, directories within
+// testdata or vendor are not part of the module.
+func (o Options) importPathOf(absDir string) (string, string, bool) {
+	moduleDir, moduleName, ok := o.findOwningModule(absDir)
+	if !ok {
+		return "", "", false
+	}
+
+	rel, err := filepath.Rel(moduleDir, absDir)
+	if err != nil {
+		return "", "", false
+	}
+	if rel == "." {
+		return moduleDir, moduleName, true
+	}
+
+	dirNames := strings.Split(filepath.ToSlash(rel), "/")
+	if slices.Contains(dirNames, "testdata") || slices.Contains(dirNames, "vendor") {
+		return "", "", false
+	}
+	return moduleDir, moduleName + "/" + strings.Join(dirNames, "/"), true
+}
+
+// findGoWork finds a go.work file in a given directory or any of its parents. As the
+// go command does, GOWORK can point to the go.work file to use or disable it with "off".
+func findGoWork(dir string) (string, bool) {
+	switch goWork := os.Getenv("GOWORK"); goWork {
+	case "off":
+		return "", false
+	case "":
+	default:
+		return goWork, true
+	}
+
+	for {
+		goWork := filepath.Join(dir, "go.work")
+		if fi, err := os.Stat(goWork); err == nil && !fi.IsDir() {
+			return goWork, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// readGoWorkModules returns the absolute directories of the modules listed in the
+// use directives of a go.work file.
+func readGoWorkModules(goWork string) ([]string, error) {
+	content, err := os.ReadFile(goWork)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %v", goWork, err)
+	}
+
+	wf, err := modfile.ParseWork(goWork, content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", goWork, err)
+	}
+
+	moduleDirs := make([]string, 0, len(wf.Use))
+	for _, use := range wf.Use {
+		moduleDir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(moduleDir) {
+			moduleDir = filepath.Join(filepath.Dir(goWork), moduleDir)
+		}
+		moduleDirs = append(moduleDirs, moduleDir)
+	}
+
+	return moduleDirs, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package porto
+
+import "os"
+
+// chown is a no-op as file ownership can't be preserved in this platform.
+func chown(*os.File, os.FileInfo) error {
+	return nil
+}
@@ -1,9 +1,12 @@
 package porto
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFindGoModule(t *testing.T) {
@@ -15,6 +18,36 @@ func TestFindGoModule(t *testing.T) {
 	assert.False(t, found)
 }
 
+func TestImportPathOf(t *testing.T) {
+	moduleDir, importPath, found := Options{}.importPathOf(mustAbs(t, "./testdata/withgomod"))
+	assert.True(t, found)
+	assert.Equal(t, mustAbs(t, "./testdata/withgomod"), moduleDir)
+	assert.Equal(t, "github.com/jcchavezs/porto/testmodule", importPath)
+
+	moduleDir, importPath, found = Options{}.importPathOf(mustAbs(t, "./cmd/porto"))
+	assert.True(t, found)
+	assert.Equal(t, mustAbs(t, "."), moduleDir)
+	assert.Equal(t, "github.com/jcchavezs/porto/cmd/porto", importPath)
+
+	_, importPath, found = Options{}.importPathOf(mustAbs(t, "./testdata/withoutgomod/more"))
+	assert.True(t, found)
+	assert.Equal(t, "github.com/jcchavezs/porto/integration/withoutgomod/more", importPath)
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                     "module example.com/m\n",
+		"vendor/github.com/x/y/y.go": "package y\n",
+	})
+	_, _, found = Options{}.importPathOf(dir + "/vendor/github.com/x/y")
+	assert.False(t, found)
+}
+
+func mustAbs(t *testing.T, path string) string {
+	absPath, err := filepath.Abs(path)
+	require.NoError(t, err)
+	return absPath
+}
+
 func TestIsGoFile(t *testing.T) {
 	assert.True(t, isGoFile("example.go"))
 	assert.False(t, isGoFile(".go"))
@@ -23,3 +56,95 @@ func TestIsGoFile(t *testing.T) {
 func TestIsGoTestFile(t *testing.T) {
 	assert.True(t, isGoFile("example_test.go"))
 }
+
+func TestWriteContentToFile(t *testing.T) {
+	t.Run("preserves permissions", func(t *testing.T) {
+		dir := t.TempDir()
+		absFilepath := dir + "/pad.go"
+		require.NoError(t, os.WriteFile(absFilepath, []byte("package pad\n"), 0600))
+
+		require.NoError(t, writeContentToFile(absFilepath, []byte("package pad\n"), []byte("package pad // import \"pad\"\n")))
+
+		content, err := os.ReadFile(absFilepath)
+		require.NoError(t, err)
+		assert.Equal(t, "package pad // import \"pad\"\n", string(content))
+
+		fi, err := os.Stat(absFilepath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file should be gone")
+	})
+
+	t.Run("preserves special permission bits", func(t *testing.T) {
+		absFilepath := t.TempDir() + "/pad.go"
+		require.NoError(t, os.WriteFile(absFilepath, []byte("package pad\n"), 0644))
+		require.NoError(t, os.Chmod(absFilepath, 0644|os.ModeSetgid|os.ModeSticky))
+
+		require.NoError(t, writeContentToFile(absFilepath, []byte("package pad\n"), []byte("package pad // import \"pad\"\n")))
+
+		fi, err := os.Stat(absFilepath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644)|os.ModeSetgid|os.ModeSticky, fi.Mode()&(os.ModePerm|os.ModeSetgid|os.ModeSticky))
+	})
+
+	t.Run("writes through symlinks", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"real/p.go": "package p\n"})
+		require.NoError(t, os.MkdirAll(dir+"/m/p", 0755))
+		require.NoError(t, os.Symlink("../../real/p.go", dir+"/m/p/p.go"))
+
+		require.NoError(t, writeContentToFile(dir+"/m/p/p.go", []byte("package p\n"), []byte("package p // import \"p\"\n")))
+
+		content, err := os.ReadFile(dir + "/real/p.go")
+		require.NoError(t, err)
+		assert.Equal(t, "package p // import \"p\"\n", string(content))
+
+		fi, err := os.Lstat(dir + "/m/p/p.go")
+		require.NoError(t, err)
+		assert.Equal(t, os.ModeSymlink, fi.Mode()&os.ModeSymlink, "the symlink is kept")
+
+		entries, err := os.ReadDir(dir + "/m/p")
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file should be gone")
+	})
+
+	t.Run("file changed since read", func(t *testing.T) {
+		absFilepath := t.TempDir() + "/pad.go"
+		require.NoError(t, os.WriteFile(absFilepath, []byte("package pad\n\nvar a = 1\n"), 0644))
+
+		err := writeContentToFile(absFilepath, []byte("package pad\n"), []byte("package pad // import \"pad\"\n"))
+		require.ErrorIs(t, err, errFileChanged)
+
+		content, err := os.ReadFile(absFilepath)
+		require.NoError(t, err)
+		assert.Equal(t, "package pad\n\nvar a = 1\n", string(content))
+	})
+}
+
+func TestFindGoWork(t *testing.T) {
+	t.Setenv("GOWORK", "")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/go.work", []byte("go 1.23\n"), 0644))
+	require.NoError(t, os.MkdirAll(dir+"/a/b", 0755))
+
+	goWork, found := findGoWork(dir + "/a/b")
+	assert.True(t, found)
+	assert.Equal(t, dir+"/go.work", goWork)
+
+	t.Setenv("GOWORK", "off")
+	_, found = findGoWork(dir + "/a/b")
+	assert.False(t, found)
+}
+
+func TestReadGoWorkModules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/go.work", []byte("go 1.23\n\nuse (\n\t./a\n\t../b\n\t/c\n)\n"), 0644))
+
+	moduleDirs, err := readGoWorkModules(dir + "/go.work")
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/a", filepath.Dir(dir) + "/b", "/c"}, moduleDirs)
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package porto
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// chown gives the file the ownership described by fi. Lacking the permissions to do
+// so is not considered an error as the file keeps the ownership of the current user.
+func chown(f *os.File, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package porto
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Supported output formats for reporting the results.
+const (
+	FormatText          = "text"
+	FormatJSON          = "json"
+	FormatSARIF         = "sarif"
+	FormatCheckstyle    = "checkstyle"
+	FormatGitHubActions = "github-actions"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatText, FormatJSON, FormatSARIF, FormatCheckstyle, FormatGitHubActions}
+
+const (
+	toolName    = "porto"
+	toolURI     = "https://github.com/jcchavezs/porto"
+	vanityRule  = "vanity-import"
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Flusher is implemented by reporters that hold the events until all files
+// have been processed, e.g. because the format needs a single document.
+type Flusher interface {
+	Flush() error
+}
+
+// NewReporter returns a reporter rendering the events in the given format.
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case FormatText:
+		return NewTextReporter(w), nil
+	case FormatJSON:
+		return &jsonReporter{w: w}, nil
+	case FormatSARIF:
+		return &sarifReporter{w: w}, nil
+	case FormatCheckstyle:
+		return &checkstyleReporter{w: w}, nil
+	case FormatGitHubActions:
+		return &githubActionsReporter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// importPathFromComment extracts the import path out of an import comment,
+// returning an empty string if the comment is not an import comment.
+func importPathFromComment(comment string) string {
+	matches := importCommentRx.FindStringSubmatch(comment)
+	if matches == nil {
+		return ""
+	}
+
+	quotedPath := matches[2]
+	if quotedPath == "" {
+		quotedPath = matches[3]
+	}
+
+	importPath, err := strconv.Unquote(quotedPath)
+	if err != nil {
+		return ""
+	}
+
+	return importPath
+}
+
+// isStyleFinding tells whether the file has the right vanity import but not written
+// in the expected style.
+func isStyleFinding(e Event) bool {
+	current := importPathFromComment(e.OldImportComment)
+	return e.NewImportComment != "" && current != "" && current == importPathFromComment(e.NewImportComment)
+}
+
+// findingMessage describes the problem found in a file.
+func findingMessage(e Event) string {
+	if e.NewImportComment == "" {
+		return fmt.Sprintf("unexpected vanity import %q", importPathFromComment(e.OldImportComment))
+	}
+	if e.Conflicting {
+		return fmt.Sprintf("conflicting vanity import %q in package, expected %q", importPathFromComment(e.OldImportComment), e.ModulePath)
+	}
+	current := importPathFromComment(e.OldImportComment)
+	if isStyleFinding(e) {
+		return fmt.Sprintf("vanity import %q not written as expected", current)
+	} else if current != "" {
+		return fmt.Sprintf("wrong vanity import %q, expected %q", current, e.ModulePath)
+	}
+	return fmt.Sprintf("missing vanity import %q", e.ModulePath)
+}
+
+type jsonFinding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Expected    string `json:"expected"`
+	Current     string `json:"current"`
+	Action      Action `json:"action"`
+	Reason      string `json:"reason,omitempty"`
+	Conflicting bool   `json:"conflicting,omitempty"`
+}
+
+type jsonReporter struct {
+	w        io.Writer
+	findings []jsonFinding
+}
+
+func (r *jsonReporter) Report(e Event) error {
+	r.findings = append(r.findings, jsonFinding{
+		File:        filepath.ToSlash(e.Path),
+		Line:        e.Line,
+		Column:      e.Column,
+		Expected:    importPathFromComment(e.NewImportComment),
+		Current:     importPathFromComment(e.OldImportComment),
+		Action:      e.Action,
+		Reason:      string(e.SkipReason),
+		Conflicting: e.Conflicting,
+	})
+	return nil
+}
+
+func (r *jsonReporter) Flush() error {
+	findings := r.findings
+	if findings == nil {
+		findings = []jsonFinding{}
+	}
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+type sarifProperties struct {
+	Expected string `json:"expected"`
+	Current  string `json:"current"`
+}
+
+type sarifReporter struct {
+	w       io.Writer
+	results []sarifResult
+}
+
+func (r *sarifReporter) Report(e Event) error {
+	if !e.Action.isFinding() {
+		return nil
+	}
+
+	r.results = append(r.results, sarifResult{
+		RuleID:  vanityRule,
+		Level:   "error",
+		Message: sarifMessage{Text: findingMessage(e)},
+		Locations: []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(e.Path)},
+				Region:           sarifRegion{StartLine: e.Line, StartColumn: e.Column},
+			},
+		}},
+		Properties: sarifProperties{
+			Expected: importPathFromComment(e.NewImportComment),
+			Current:  importPathFromComment(e.OldImportComment),
+		},
+	})
+	return nil
+}
+
+func (r *sarifReporter) Flush() error {
+	results := r.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules: []sarifRule{{
+					ID:               vanityRule,
+					ShortDescription: sarifMessage{Text: "Package clause is missing the right vanity import"},
+				}},
+			}},
+			Results: results,
+		}},
+	})
+}
+
+type checkstyleLog struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type checkstyleReporter struct {
+	w     io.Writer
+	files []checkstyleFile
+}
+
+func (r *checkstyleReporter) Report(e Event) error {
+	if !e.Action.isFinding() {
+		return nil
+	}
+
+	r.files = append(r.files, checkstyleFile{
+		Name: filepath.ToSlash(e.Path),
+		Errors: []checkstyleError{{
+			Line:     e.Line,
+			Column:   e.Column,
+			Severity: "error",
+			Message:  findingMessage(e),
+			Source:   toolName + "." + vanityRule,
+		}},
+	})
+	return nil
+}
+
+func (r *checkstyleReporter) Flush() error {
+	if _, err := io.WriteString(r.w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(r.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(checkstyleLog{Version: "5.0", Files: r.files}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(r.w, "\n")
+	return err
+}
+
+type githubActionsReporter struct {
+	w io.Writer
+}
+
+// Report prints a workflow command so the finding shows up as an annotation.
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+func (r *githubActionsReporter) Report(e Event) error {
+	if !e.Action.isFinding() {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(
+		r.w,
+		"::error file=%s,line=%d,col=%d,title=%s::%s\n",
+		escapeGitHubActionsProperty(filepath.ToSlash(e.Path)),
+		e.Line,
+		e.Column,
+		toolName,
+		escapeGitHubActionsData(findingMessage(e)),
+	)
+	return err
+}
+
+var (
+	githubActionsDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubActionsPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func escapeGitHubActionsData(s string) string {
+	return githubActionsDataEscaper.Replace(s)
+}
+
+func escapeGitHubActionsProperty(s string) string {
+	return githubActionsPropertyEscaper.Replace(s)
+}
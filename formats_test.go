@@ -0,0 +1,115 @@
+package porto
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var wrongImportEvent = Event{
+	Path:             "rightpad/rightpad.go",
+	Line:             1,
+	Column:           1,
+	ModulePath:       "jcchavezs.github.io/porto/integration/rightpad",
+	OldImportComment: "// import \"wrong/import/rightpad\"",
+	NewImportComment: "// import \"jcchavezs.github.io/porto/integration/rightpad\"",
+	Action:           ActionListed,
+}
+
+func reportAndFlush(t *testing.T, format string, events ...Event) string {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	r, err := NewReporter(format, buf)
+	require.NoError(t, err)
+	for _, e := range events {
+		require.NoError(t, r.Report(e))
+	}
+	if f, ok := r.(Flusher); ok {
+		require.NoError(t, f.Flush())
+	}
+	return buf.String()
+}
+
+func TestNewReporter(t *testing.T) {
+	_, err := NewReporter("yaml", &bytes.Buffer{})
+	assert.EqualError(t, err, "unknown format \"yaml\", expected one of text, json, sarif, checkstyle, github-actions")
+}
+
+func TestJSONReporter(t *testing.T) {
+	t.Run("no findings", func(t *testing.T) {
+		assert.Equal(t, "[]\n", reportAndFlush(t, FormatJSON))
+	})
+
+	t.Run("findings", func(t *testing.T) {
+		var findings []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(reportAndFlush(t, FormatJSON, wrongImportEvent)), &findings))
+		assert.Equal(t, []map[string]interface{}{{
+			"file":     "rightpad/rightpad.go",
+			"line":     float64(1),
+			"column":   float64(1),
+			"expected": "jcchavezs.github.io/porto/integration/rightpad",
+			"current":  "wrong/import/rightpad",
+			"action":   "listed",
+		}}, findings)
+	})
+}
+
+func TestSARIFReporter(t *testing.T) {
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(reportAndFlush(t, FormatSARIF, wrongImportEvent)), &log))
+	require.Len(t, log.Runs, 1)
+	require.Len(t, log.Runs[0].Results, 1)
+
+	result := log.Runs[0].Results[0]
+	assert.Equal(t, vanityRule, result.RuleID)
+	assert.Equal(t, "wrong vanity import \"wrong/import/rightpad\", expected \"jcchavezs.github.io/porto/integration/rightpad\"", result.Message.Text)
+	assert.Equal(t, "rightpad/rightpad.go", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, sarifRegion{StartLine: 1, StartColumn: 1}, result.Locations[0].PhysicalLocation.Region)
+}
+
+func TestStructuredReportersIgnoreNonFindings(t *testing.T) {
+	skipped := Event{Path: "codegen/generated.go", Action: ActionSkipped, SkipReason: SkipGenerated}
+	assert.Empty(t, reportAndFlush(t, FormatGitHubActions, skipped))
+	assert.Contains(t, reportAndFlush(t, FormatSARIF, skipped), `"results": []`)
+	assert.Contains(t, reportAndFlush(t, FormatJSON, skipped), `"reason": "generated file"`)
+}
+
+func TestCheckstyleReporter(t *testing.T) {
+	assert.Equal(
+		t,
+		`<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="5.0">
+  <file name="leftpad/leftpad.go">
+    <error line="3" column="1" severity="error" message="missing vanity import &#34;leftpad&#34;" source="porto.vanity-import"></error>
+  </file>
+</checkstyle>
+`,
+		reportAndFlush(t, FormatCheckstyle, Event{
+			Path:             "leftpad/leftpad.go",
+			Line:             3,
+			Column:           1,
+			ModulePath:       "leftpad",
+			NewImportComment: "// import \"leftpad\"",
+		}),
+	)
+}
+
+func TestGitHubActionsReporter(t *testing.T) {
+	assert.Equal(
+		t,
+		"::error file=rightpad/rightpad.go,line=1,col=1,title=porto::wrong vanity import \"wrong/import/rightpad\", "+
+			"expected \"jcchavezs.github.io/porto/integration/rightpad\"\n",
+		reportAndFlush(t, FormatGitHubActions, wrongImportEvent),
+	)
+}
+
+func TestImportPathFromComment(t *testing.T) {
+	assert.Equal(t, "a/b", importPathFromComment(`// import "a/b"`))
+	assert.Equal(t, "a/b", importPathFromComment(`/* import "a/b" */`))
+	assert.Empty(t, importPathFromComment("// nolint"))
+	assert.Empty(t, importPathFromComment(""))
+}
@@ -0,0 +1,151 @@
+package porto
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fsRoot is the directory the files of a walked fs.FS are resolved against.
+const fsRoot = "."
+
+// Edit replaces the bytes of a file from Start to End, both offsets in its original
+// content, with NewText.
+type Edit struct {
+	Start, End int
+	NewText    []byte
+}
+
+// Edits returns the edits turning the original content of a go file into the new one,
+// nil if they are the same.
+func Edits(original, content []byte) []Edit {
+	start, end, newText := textEdit(original, content)
+	if start == end && len(newText) == 0 {
+		return nil
+	}
+	return []Edit{{Start: start, End: end, NewText: newText}}
+}
+
+// Change holds a go file whose vanity import differs from porto's.
+type Change struct {
+	// Slash separated path of the file in the walked file system
+	Path string
+	// Content of the file as read
+	OriginalContent []byte
+	// Content of the file once its vanity import is fixed
+	Content []byte
+	// Edits turning the original content into the new one
+	Edits []Edit
+}
+
+// AddVanityImport returns the content of a go file with the vanity import of the given
+// import path, keeping the style of the current one if any. The content is returned as
+// is for main packages, generated files and files opted out with the ignore directive.
+func AddVanityImport(content []byte, importPath string) ([]byte, error) {
+	_, newContent, _, _, err := addImportPathToContent("", content, importPath, "")
+	return contentOrSkipped(content, newContent, err)
+}
+
+// RemoveVanityImport returns the content of a go file without its vanity import. The
+// content is returned as is for main packages, generated files and files opted out
+// with the ignore directive.
+func RemoveVanityImport(content []byte) ([]byte, error) {
+	_, newContent, _, err := removeImportPathFromContent("", content)
+	return contentOrSkipped(content, newContent, err)
+}
+
+// contentOrSkipped returns the new content of a go file, or the original one if porto
+// skips the file.
+func contentOrSkipped(content, newContent []byte, err error) ([]byte, error) {
+	switch err {
+	case nil:
+		return newContent, nil
+	case errMainPackage, errGenerated, errIgnored:
+		return content, nil
+	default:
+		return nil, err
+	}
+}
+
+// FindAndAddVanityImportForFS walks a file system as FindAndAddVanityImportForDir does
+// with a directory, returning the go files whose vanity import differs instead of
+// writing them. The import paths are based on the go.mod files found in it and the
+// changed files, if any, are given as paths in it. The results are also reported to
+// the reporter in the options if set. Working with workspaces is not supported.
+func FindAndAddVanityImportForFS(fsys fs.FS, opts Options) ([]Change, error) {
+	if opts.UseWorkspace {
+		return nil, fmt.Errorf("failed to walk the file system: workspaces are not supported")
+	}
+
+	opts.fsys = fsys
+	opts.WriteResultToFile = false
+
+	if opts.ChangedFiles != nil {
+		opts.changedFiles = make(map[string]struct{}, len(opts.ChangedFiles))
+		for _, name := range opts.ChangedFiles {
+			opts.changedFiles[fsRoot+pathSeparator+filepath.FromSlash(name)] = struct{}{}
+		}
+		opts.ChangedFiles = nil
+	}
+
+	r := &changesReporter{next: opts.Reporter}
+	opts.Reporter = r
+
+	_, err := run(opts, func(opts Options) (int, error) {
+		return findAndAddVanityImportForDir(fsRoot, fsRoot, opts)
+	})
+	return r.changes, err
+}
+
+// changesReporter collects the changes out of the reported events, passing them to the
+// next reporter if any.
+type changesReporter struct {
+	changes []Change
+	next    Reporter
+}
+
+func (r *changesReporter) Report(e Event) error {
+	if e.Content != nil {
+		r.changes = append(r.changes, Change{
+			Path:            filepath.ToSlash(e.Path),
+			OriginalContent: e.OriginalContent,
+			Content:         e.Content,
+			Edits:           Edits(e.OriginalContent, e.Content),
+		})
+	}
+
+	if r.next == nil {
+		return nil
+	}
+	return r.next.Report(e)
+}
+
+// fsPath returns the name in the walked file system of a path resolved against fsRoot.
+func fsPath(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// readDir reads a directory from the walked file system, or from disk if none.
+func (o Options) readDir(absDir string) ([]fs.DirEntry, error) {
+	if o.fsys == nil {
+		return os.ReadDir(absDir)
+	}
+	return fs.ReadDir(o.fsys, fsPath(absDir))
+}
+
+// readFile reads a file from the walked file system, or from disk if none.
+func (o Options) readFile(absFilepath string) ([]byte, error) {
+	if o.fsys == nil {
+		return os.ReadFile(absFilepath)
+	}
+	return fs.ReadFile(o.fsys, fsPath(absFilepath))
+}
+
+// stat describes a file from the walked file system, or from disk if none.
+func (o Options) stat(absPath string) (fs.FileInfo, error) {
+	if o.fsys == nil {
+		return os.Stat(absPath)
+	}
+	return fs.Stat(o.fsys, fsPath(absPath))
+}
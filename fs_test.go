@@ -0,0 +1,100 @@
+package porto
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddVanityImport(t *testing.T) {
+	content, err := AddVanityImport([]byte("package pad // import \"example.com/old\"\n\nvar A = 1\n"), "example.com/pad")
+	require.NoError(t, err)
+	assert.Equal(t, "package pad // import \"example.com/pad\"\n\nvar A = 1\n", string(content))
+
+	content, err = RemoveVanityImport([]byte("package pad // import \"example.com/pad\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "package pad\n", string(content))
+
+	for _, skipped := range []string{
+		"package main\n",
+		"// Code generated by gen. DO NOT EDIT.\n\npackage pad\n",
+		"//porto:ignore\n\npackage pad\n",
+	} {
+		content, err = AddVanityImport([]byte(skipped), "example.com/pad")
+		require.NoError(t, err)
+		assert.Equal(t, skipped, string(content))
+	}
+
+	_, err = AddVanityImport([]byte("pad\n"), "example.com/pad")
+	assert.Error(t, err)
+}
+
+func TestEdits(t *testing.T) {
+	assert.Equal(t, []Edit{{Start: 11, End: 11, NewText: []byte(" // import \"pad\"")}}, Edits([]byte("package pad\n"), []byte("package pad // import \"pad\"\n")))
+	assert.Nil(t, Edits([]byte("package pad\n"), []byte("package pad\n")))
+}
+
+func TestFindAndAddVanityImportForFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"go.mod":                  {Data: []byte("module example.com/repo\n")},
+		"repo.go":                 {Data: []byte("package repo // import \"example.com/repo\"\n")},
+		"pad/pad.go":              {Data: []byte("package pad\n")},
+		"pad/pad_linux.go":        {Data: []byte("package pad\n")},
+		"pad/internal/in.go":      {Data: []byte("package internal\n")},
+		"generated/.porto.yaml":   {Data: []byte("skip-files: ['\\.pb\\.go$']\n")},
+		"generated/gen.pb.go":     {Data: []byte("package generated\n")},
+		"nested/go.mod":           {Data: []byte("module example.com/nested\n")},
+		"nested/nested.go":        {Data: []byte("package nested\n")},
+		"testdata/fixture/fix.go": {Data: []byte("package fixture\n")},
+	}
+
+	paths := func(changes []Change) []string {
+		var paths []string
+		for _, c := range changes {
+			paths = append(paths, c.Path)
+		}
+		return paths
+	}
+
+	t.Run("walks the file system", func(t *testing.T) {
+		r := &recordingReporter{}
+		changes, err := FindAndAddVanityImportForFS(fsys, Options{WriteResultToFile: true, UseConfigFiles: true, Reporter: r})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"nested/nested.go", "pad/pad.go", "pad/pad_linux.go"}, paths(changes))
+		assert.Equal(t, []byte("package pad\n"), changes[1].OriginalContent)
+		assert.Equal(t, "package pad // import \"example.com/repo/pad\"\n", string(changes[1].Content))
+		assert.Equal(t, []Edit{{Start: 11, End: 11, NewText: []byte(" // import \"example.com/repo/pad\"")}}, changes[1].Edits)
+		assert.Equal(t, "package nested // import \"example.com/nested\"\n", string(changes[0].Content))
+
+		require.Len(t, r.events, 3)
+		assert.Equal(t, ActionPreviewed, r.events[0].Action)
+		assert.Equal(t, "package pad\n", string(fsys["pad/pad.go"].Data), "the file system is not written")
+	})
+
+	t.Run("options", func(t *testing.T) {
+		changes, err := FindAndAddVanityImportForFS(fsys, Options{
+			BuildContext:    NewBuildContext("windows", "amd64", nil),
+			IncludeInternal: true,
+			Concurrency:     4,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"generated/gen.pb.go", "nested/nested.go", "pad/pad.go", "pad/internal/in.go"}, paths(changes))
+
+		changes, err = FindAndAddVanityImportForFS(fsys, Options{ChangedFiles: []string{"pad/pad.go", "repo.go"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pad/pad.go"}, paths(changes))
+
+		changes, err = FindAndAddVanityImportForFS(fsys, Options{RemoveVanityImport: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo.go"}, paths(changes))
+		assert.Equal(t, "package repo\n", string(changes[0].Content))
+	})
+
+	t.Run("workspaces are not supported", func(t *testing.T) {
+		_, err := FindAndAddVanityImportForFS(fsys, Options{UseWorkspace: true})
+		assert.ErrorContains(t, err, "workspaces are not supported")
+	})
+}
@@ -0,0 +1,74 @@
+package porto
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs a git command in the given directory and returns its output.
+func runGit(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// ChangedGoFiles returns the absolute paths of the go files changed in the git repository
+// holding the given directory. When staged is true it returns the files staged in the
+// index, compared to the given revision if any. Otherwise it returns the files changed
+// in the working tree since the given revision, including the untracked ones. Deleted
+// files are left out. The paths have their symlinks resolved.
+func ChangedGoFiles(dir, rev string, staged bool) ([]string, error) {
+	if rev == "" && !staged {
+		return nil, fmt.Errorf("failed to find changed files: either a revision or the staged files are required")
+	}
+
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	rootDir := strings.TrimSpace(string(out))
+	if rootDir, err = filepath.EvalSymlinks(rootDir); err != nil {
+		return nil, fmt.Errorf("failed to resolve the root of the git repository: %v", err)
+	}
+
+	diffArgs := []string{"diff", "--name-only", "-z", "--no-renames", "--diff-filter=ACM"}
+	if staged {
+		diffArgs = append(diffArgs, "--cached")
+	}
+	if rev != "" {
+		diffArgs = append(diffArgs, rev, "--")
+	}
+
+	out, err = runGit(rootDir, diffArgs...)
+	if err != nil {
+		return nil, err
+	}
+	names := strings.Split(string(out), "\x00")
+
+	if !staged {
+		out, err = runGit(rootDir, "ls-files", "-z", "--others", "--exclude-standard")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, strings.Split(string(out), "\x00")...)
+	}
+
+	files := []string{}
+	for _, name := range names {
+		if isGoFile(filepath.Base(name)) {
+			files = append(files, filepath.Join(rootDir, filepath.FromSlash(name)))
+		}
+	}
+
+	return files, nil
+}
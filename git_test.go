@@ -0,0 +1,81 @@
+package porto
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedGoFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	// the paths are returned with their symlinks resolved, e.g. /private/var in macOS
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	git := func(args ...string) {
+		_, err := runGit(dir, append([]string{"-c", "user.name=porto", "-c", "user.email=porto@example.com"}, args...)...)
+		require.NoError(t, err)
+	}
+
+	git("init", "-q")
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/repo\n",
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n",
+		"c/c.go": "package c\n",
+	})
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	writeFiles(t, dir, map[string]string{
+		"a/a.go":      "package a\n\nvar A = 1\n",
+		"b/b.go":      "package b\n\nvar B = 1\n",
+		"b/README.md": "b\n",
+		"d/d.go":      "package d\n",
+	})
+	require.NoError(t, os.Remove(dir+"/c/c.go"))
+	git("add", "a/a.go")
+
+	files, err := ChangedGoFiles(dir+"/a", "", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/a/a.go"}, files)
+
+	files, err = ChangedGoFiles(dir, "HEAD", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir + "/a/a.go", dir + "/b/b.go", dir + "/d/d.go"}, files)
+
+	t.Run("through a symlinked directory", func(t *testing.T) {
+		link := t.TempDir() + "/link"
+		require.NoError(t, os.Symlink(dir, link))
+
+		files, err := ChangedGoFiles(link, "HEAD", false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{dir + "/a/a.go", dir + "/b/b.go", dir + "/d/d.go"}, files)
+
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(link, link, Options{ListDiffFiles: true, ChangedFiles: files, Reporter: r})
+		require.NoError(t, err)
+		assert.Equal(t, 3, c)
+		require.Len(t, r.events, 3)
+		assert.Equal(t, "a/a.go", r.events[0].Path)
+	})
+
+	git("commit", "-q", "-m", "second")
+
+	files, err = ChangedGoFiles(dir, "", true)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.NotNil(t, files)
+
+	_, err = ChangedGoFiles(dir, "", false)
+	assert.EqualError(t, err, "failed to find changed files: either a revision or the staged files are required")
+
+	_, err = ChangedGoFiles(dir, "unknown", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,17 @@
+package porto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes the given files, by path relative to dir, creating their directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(dir+"/"+name), 0755))
+		require.NoError(t, os.WriteFile(dir+"/"+name, []byte(content), 0644))
+	}
+}
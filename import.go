@@ -2,25 +2,51 @@ package porto
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"io/fs"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
 var (
 	errMainPackage = errors.New("failed to add import to a main package")
 	errGenerated   = errors.New("failed to add import to a generated file")
+	errIgnored     = errors.New("failed to add import to a file opted out with " + ignoreDirective)
 	// Matches https://golang.org/s/generatedcode and cgo generated comment.
 	// Taken from https://github.com/golang/tools/blob/c5188f24a/refactor/rename/spec.go#L574-L576
 	generatedRx = regexp.MustCompile(`// .*DO NOT EDIT\.?`)
+	// Matches both forms of import comments, see https://golang.org/s/go14customimport
+	importCommentRx = regexp.MustCompile(`^(//\s*import\s+` + quotedPathRx + `\s*|/\*\s*import\s+` + quotedPathRx + `\s*\*/)$`)
+	// Matches the directive opting a file out of porto, optionally followed by the reason
+	ignoreDirectiveRx = regexp.MustCompile(`^` + ignoreDirective + `(\s|$)`)
 )
 
+// ignoreDirective opts the file holding it in its header out of porto, or the whole
+// package when found in doc.go.
+const ignoreDirective = "//porto:ignore"
+
+// Styles of the vanity imports porto writes.
+const (
+	ImportCommentLine  = "line"
+	ImportCommentBlock = "block"
+)
+
+// ImportCommentStyles lists the styles of the vanity imports porto can write.
+var ImportCommentStyles = []string{ImportCommentLine, ImportCommentBlock}
+
+const quotedPathRx = `("[^"]*"|` + "`[^`]*`)"
+
 // isGeneratedFile reports whether ast.File is a generated file.
 // Taken from https://github.com/golang/tools/blob/c5188f24a/refactor/rename/spec.go#L578-L593
 func isGeneratedFile(pf *ast.File, tokenFile *token.File) bool {
@@ -38,55 +64,217 @@ func isGeneratedFile(pf *ast.File, tokenFile *token.File) bool {
 	return false
 }
 
-// addImportPath adds the vanity import path to a given go file.
-func addImportPath(absFilepath string, module string) (bool, []byte, error) {
+// hasIgnoreDirective tells whether the header of a go file, i.e. the comments before
+// the package clause, holds the ignore directive.
+func hasIgnoreDirective(pf *ast.File) bool {
+	for _, commentGroup := range pf.Comments {
+		if commentGroup.Pos() >= pf.Package {
+			break
+		}
+
+		for _, comment := range commentGroup.List {
+			if ignoreDirectiveRx.MatchString(comment.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPackageIgnoreDirective tells whether the doc.go file of a directory opts the given
+// package out of porto, even if the file itself is skipped.
+func (o Options) hasPackageIgnoreDirective(absDir, packageName string) bool {
+	content, err := o.readFile(absDir + pathSeparator + "doc.go")
+	if err != nil {
+		return false
+	}
+
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, absDir+pathSeparator+"doc.go", content, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+
+	return pf.Name.Name == packageName && hasIgnoreDirective(pf)
+}
+
+// packageClause holds the details of the package clause of a go file.
+type packageClause struct {
+	// line and column of the package keyword
+	line, column int
+	// comment trailing the package clause, if any
+	importComment string
+}
+
+// addImportPath adds the vanity import path to a given go file. Along with the new
+// content it returns the details of the original package clause.
+func addImportPath(absFilepath string, module string) (bool, []byte, packageClause, error) {
+	content, err := os.ReadFile(absFilepath)
+	if err != nil {
+		return false, nil, packageClause{}, fmt.Errorf("failed to read the file %q: %v", absFilepath, err)
+	}
+
+	hasChanged, newContent, clause, _, err := addImportPathToContent(absFilepath, content, module, "")
+	return hasChanged, newContent, clause, err
+}
+
+// parseGoFile parses the content of a go file, failing for the files that can't
+// hold a vanity import.
+func parseGoFile(absFilepath string, content []byte) (*token.FileSet, *ast.File, error) {
 	fset := token.NewFileSet()
-	pf, err := parser.ParseFile(fset, absFilepath, nil, parser.ParseComments)
+	pf, err := parser.ParseFile(fset, absFilepath, content, parser.ParseComments)
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to parse the file %q: %v", absFilepath, err)
+		return nil, nil, fmt.Errorf("failed to parse the file %q: %v", absFilepath, err)
 	}
 	packageName := pf.Name.String()
 	if packageName == "main" { // you can't import a main package
-		return false, nil, errMainPackage
+		return nil, nil, errMainPackage
 	}
 
 	// Skip generated files.
 	tokenFile := fset.File(pf.Pos())
 	if isGeneratedFile(pf, tokenFile) {
-		return false, nil, errGenerated
+		return nil, nil, errGenerated
 	}
 
-	content, err := os.ReadFile(absFilepath)
+	return fset, pf, nil
+}
+
+// findImportComment returns the import comment trailing the package clause, if any. As
+// for the go command, it has to be on the line of the package name, which may not be the
+// one of the package keyword.
+func findImportComment(fset *token.FileSet, pf *ast.File) *ast.Comment {
+	nameLine := fset.Position(pf.Name.End()).Line
+	for _, commentGroup := range pf.Comments {
+		for _, comment := range commentGroup.List {
+			if comment.Pos() < pf.Name.End() || fset.Position(comment.Pos()).Line != nameLine {
+				continue
+			}
+
+			if importCommentRx.MatchString(comment.Text) {
+				return comment
+			}
+		}
+	}
+	return nil
+}
+
+// newPackageClause returns the details of the package clause of a parsed go file.
+func newPackageClause(fset *token.FileSet, pf *ast.File) packageClause {
+	packagePos := fset.Position(pf.Package)
+	clause := packageClause{
+		line:   packagePos.Line,
+		column: packagePos.Column,
+	}
+	if c := findImportComment(fset, pf); c != nil {
+		clause.importComment = c.Text
+	}
+	return clause
+}
+
+// addImportPathToContent adds the vanity import path to the content of a given go file.
+// As required by the go command, the vanity import follows the package name right away,
+// other comments trailing the package clause are kept byte for byte after it. An empty
+// style keeps the one of the current vanity import, if any, preferring line comments.
+// Along with the new content it returns the vanity import written.
+func addImportPathToContent(absFilepath string, content []byte, module, style string) (bool, []byte, packageClause, string, error) {
+	fset, pf, err := parseGoFile(absFilepath, content)
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to parse the file %q: %v", absFilepath, err)
+		return false, nil, packageClause{}, "", err
+	}
+	if hasIgnoreDirective(pf) {
+		return false, nil, packageClause{}, "", errIgnored
 	}
 
-	// 9 = len("package ") + 1 because that is the first character of the package name
-	startPackageLinePos := int(pf.Name.NamePos) - 9
+	tokenFile := fset.File(pf.Pos())
+	start := tokenFile.Offset(pf.Name.End())
 
-	// first 1 = len(" ") as in "package " and the other 1 is for newline
-	endPackageLinePos := pf.Name.NamePos
-	newLineChar := byte(10)
-	for {
-		// we look for new lines in case we already had comments next to the package or
-		// another vanity import
-		if content[endPackageLinePos] == newLineChar {
-			break
+	// rest holds the content following the package name without the current vanity import
+	rest := content[start:]
+	blockStyle := style == ImportCommentBlock
+	if comment := findImportComment(fset, pf); comment != nil {
+		if style == "" {
+			blockStyle = strings.HasPrefix(comment.Text, "/*")
+		}
+
+		commentStart, commentEnd := tokenFile.Offset(comment.Pos()), tokenFile.Offset(comment.End())
+		for commentStart > start && (content[commentStart-1] == ' ' || content[commentStart-1] == '\t') {
+			commentStart--
 		}
-		endPackageLinePos++
+		rest = append(append([]byte{}, content[start:commentStart]...), content[commentEnd:]...)
 	}
 
-	importComment := []byte(" // import \"" + module + "\"")
+	line := lineRest(rest, 0)
+	isLineEmpty := len(bytes.TrimSpace(line)) == 0
+	if isLineEmpty {
+		// trailing whitespace is dropped
+		rest = rest[len(line):]
+	}
 
-	newContent := []byte{}
-	if startPackageLinePos != 0 {
-		newContent = append(newContent, content[0:startPackageLinePos]...)
+	importComment := newImportComment(module)
+	if blockStyle || !isLineEmpty {
+		// a line comment would swallow the comments or code following the package clause
+		importComment = newBlockImportComment(module)
 	}
-	newContent = append(newContent, []byte("package "+packageName)...)
+
+	newContent := make([]byte, 0, start+len(importComment)+1+len(rest))
+	newContent = append(newContent, content[:start]...)
+	newContent = append(newContent, ' ')
 	newContent = append(newContent, importComment...)
-	newContent = append(newContent, content[endPackageLinePos:]...)
+	newContent = append(newContent, rest...)
+
+	return !bytes.Equal(content, newContent), newContent, newPackageClause(fset, pf), importComment, nil
+}
+
+// lineRest returns the content from the given offset to the end of its line, excluding
+// the line ending.
+func lineRest(content []byte, offset int) []byte {
+	rest := content[offset:]
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	return bytes.TrimSuffix(rest, []byte("\r"))
+}
+
+// removeImportPathFromContent removes the vanity import path from the content of a
+// given go file, keeping any other comment trailing the package clause.
+func removeImportPathFromContent(absFilepath string, content []byte) (bool, []byte, packageClause, error) {
+	fset, pf, err := parseGoFile(absFilepath, content)
+	if err != nil {
+		return false, nil, packageClause{}, err
+	}
+	if hasIgnoreDirective(pf) {
+		return false, nil, packageClause{}, errIgnored
+	}
+
+	clause := newPackageClause(fset, pf)
+	comment := findImportComment(fset, pf)
+	if comment == nil {
+		return false, content, clause, nil
+	}
+
+	start := fset.Position(comment.Pos()).Offset
+	end := fset.Position(comment.End()).Offset
+	// we also remove the whitespace separating the comment from the package clause
+	for start > 0 && (content[start-1] == ' ' || content[start-1] == '\t') {
+		start--
+	}
+
+	newContent := make([]byte, 0, len(content)-(end-start))
+	newContent = append(newContent, content[:start]...)
+	newContent = append(newContent, content[end:]...)
 
-	return !bytes.Equal(content, newContent), newContent, nil
+	return true, newContent, clause, nil
+}
+
+// newImportComment returns the import comment for a given module.
+func newImportComment(module string) string {
+	return "// import \"" + module + "\""
+}
+
+// newBlockImportComment returns the import comment for a given module as a block comment.
+func newBlockImportComment(module string) string {
+	return "/* import \"" + module + "\" */"
 }
 
 func isUnexportedModule(moduleName string, includeInternal bool) bool {
@@ -95,114 +283,491 @@ func isUnexportedModule(moduleName string, includeInternal bool) bool {
 }
 
 func findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDir string, moduleName string, opts Options) (int, error) {
-	if isUnexportedModule(moduleName, opts.IncludeInternal) {
+	if !opts.hasChangesIn(absDir) {
 		return 0, nil
 	}
 
-	files, err := os.ReadDir(absDir)
+	if absDir != baseAbsDir {
+		var err error
+		if opts, err = opts.withDirConfig(absDir); err != nil {
+			return 0, err
+		}
+	}
+
+	if isUnexportedModule(moduleName, opts.IncludeInternal) {
+		return 0, explainSkip(opts, workingDir, absDir, moduleName, SkipInternalModule)
+	}
+
+	files, err := opts.readDir(absDir)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read the content of %q: %v", absDir, err)
 	}
 
-	gc := 0
-	for _, f := range files {
-		if isDir, dirName := f.IsDir(), f.Name(); isDir {
-			var (
-				c   int
-				err error
-			)
-
-			relDir := ""
-			if baseAbsDir != absDir {
-				relDir, err = filepath.Rel(baseAbsDir, absDir)
-				if err != nil {
-					return 0, fmt.Errorf("failed to resolve relative path: %v", err)
-				}
-				relDir += pathSeparator
+	var errs []error
+	entries, err := classifyDirEntries(workingDir, absDir, moduleName, files, opts, &errs)
+	if err != nil {
+		return 0, err
+	}
+
+	var gc int
+	if entries.hasFilesToProcess && opts.scheduler != nil {
+		opts.scheduler.schedule(opts, func(opts Options) (int, error) {
+			return findAndAddVanityImportForPackageFiles(workingDir, absDir, entries.fileNames, moduleName, opts)
+		})
+	} else if entries.hasFilesToProcess {
+		if gc, err = findAndAddVanityImportForPackageFiles(workingDir, absDir, entries.fileNames, moduleName, opts); err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
+				return 0, err
 			}
+		}
+	}
+
+	if opts.nonRecursive {
+		return gc, joinErrors(errs...)
+	}
+
+	for _, dirName := range entries.dirNames {
+		if !opts.hasChangesIn(absDir + pathSeparator + dirName) {
+			continue
+		}
+
+		var c int
 
-			if isUnexportedDir(dirName, opts.IncludeInternal) {
+		reason, err := dirSkipReason(opts, baseAbsDir, absDir+pathSeparator+dirName)
+		if err != nil {
+			return 0, err
+		}
+
+		if reason != "" {
+			if err := explainSkip(opts, workingDir, absDir+pathSeparator+dirName, moduleName+"/"+dirName, reason); err != nil {
+				return 0, err
+			}
+			continue
+		} else if newModuleName, ok := opts.findGoModule(absDir + pathSeparator + dirName); ok {
+			if opts.skipNestedModules {
+				// in a workspace, nested modules are processed only if listed in go.work, and
+				// package patterns do not match them as for the go command
+				if err := explainSkip(opts, workingDir, absDir+pathSeparator+dirName, newModuleName, SkipNestedModule); err != nil {
+					return 0, err
+				}
 				continue
-			} else if len(opts.RestrictToDirsRegexes) > 0 && !matchesAny(opts.RestrictToDirsRegexes, relDir+dirName) {
+			}
+
+			// if folder contains go.mod we use it from now on to build the vanity import
+			c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDir+pathSeparator+dirName, newModuleName, opts)
+		} else {
+			// if not, we add the folder name to the vanity import
+			c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDir+pathSeparator+dirName, moduleName+"/"+dirName, opts)
+		}
+		if err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
+				return 0, err
+			}
+		}
+
+		gc += c
+	}
+
+	return gc, joinErrors(errs...)
+}
+
+// dirEntries holds the entries of a directory to be walked.
+type dirEntries struct {
+	dirNames  []string
+	fileNames []string
+	// whether any of the go files is to be processed, the rest being only read to know
+	// about their package
+	hasFilesToProcess bool
+}
+
+// classifyDirEntries splits the entries of a directory into its subdirectories and the
+// go files to be evaluated, reporting the go files skipped.
+func classifyDirEntries(workingDir, absDir, moduleName string, files []fs.DirEntry, opts Options, errs *[]error) (dirEntries, error) {
+	var entries dirEntries
+	for _, f := range files {
+		if f.IsDir() {
+			entries.dirNames = append(entries.dirNames, f.Name())
+			continue
+		}
+
+		fileName := f.Name()
+		if !isGoFile(fileName) {
+			continue
+		}
+
+		absFilepath := absDir + pathSeparator + fileName
+		reason := fileSkipReason(opts, fileName)
+		if reason == "" {
+			var err error
+			if reason, err = buildSkipReason(opts, absDir, fileName, nil); err != nil {
+				if err := opts.handleError(errs, err); err != nil {
+					return dirEntries{}, err
+				}
 				continue
-			} else if len(opts.SkipDirsRegexes) > 0 && matchesAny(opts.SkipDirsRegexes, relDir+dirName) {
+			}
+		}
+		if reason != "" {
+			if !opts.isChanged(absFilepath) {
 				continue
-			} else if newModuleName, ok := findGoModule(absDir + pathSeparator + dirName); ok {
-				// if folder contains go.mod we use it from now on to build the vanity import
-				c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDir+pathSeparator+dirName, newModuleName, opts)
-				if err != nil {
-					return 0, err
+			}
+			if err := explainSkip(opts, workingDir, absFilepath, moduleName, reason); err != nil {
+				return dirEntries{}, err
+			}
+			continue
+		}
+
+		// files are evaluated once we know all the files in the directory
+		entries.fileNames = append(entries.fileNames, fileName)
+		entries.hasFilesToProcess = entries.hasFilesToProcess || opts.isChanged(absFilepath)
+	}
+
+	return entries, nil
+}
+
+// processGoFile adds or removes the vanity import of a go file and reports the result
+// on top of the given event. It returns whether the file had to be changed.
+func processGoFile(workingDir, absFilepath string, content []byte, opts Options, e Event) (bool, error) {
+	var (
+		hasChanged bool
+		newContent []byte
+		clause     packageClause
+		err        error
+		moduleName = e.ModulePath
+	)
+	if opts.RemoveVanityImport {
+		hasChanged, newContent, clause, err = removeImportPathFromContent(absFilepath, content)
+	} else {
+		hasChanged, newContent, clause, e.NewImportComment, err = addImportPathToContent(absFilepath, content, moduleName, opts.ImportCommentStyle)
+	}
+	if err != nil {
+		return false, explainParseError(opts, workingDir, absFilepath, moduleName, err)
+	}
+
+	e.Line, e.Column = clause.line, clause.column
+	e.OldImportComment = clause.importComment
+	if !hasChanged {
+		return false, explain(opts, workingDir, absFilepath, e, ActionUnchanged)
+	}
+
+	e.OriginalContent = content
+	if err := handleNilErrorCase(opts, absFilepath, newContent, workingDir, e); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// explainParseError reports the files skipped because they can't hold a vanity import,
+// returning any other error.
+func explainParseError(opts Options, workingDir, absFilepath, moduleName string, err error) error {
+	switch err {
+	case errMainPackage:
+		return explainSkip(opts, workingDir, absFilepath, moduleName, SkipMainPackage)
+	case errGenerated:
+		return explainSkip(opts, workingDir, absFilepath, moduleName, SkipGenerated)
+	case errIgnored:
+		return explainSkip(opts, workingDir, absFilepath, moduleName, SkipIgnoreDirective)
+	default:
+		return fmt.Errorf("failed to add vanity import path to %q: %v", absFilepath, err)
+	}
+}
+
+// packageFile holds a go file to be evaluated along with the rest of its package.
+type packageFile struct {
+	name        string
+	absFilepath string
+	content     []byte
+	clause      packageClause
+}
+
+// findAndAddVanityImportForPackageFiles adds or removes the vanity import of the files of a
+// directory grouped by package. Directories holding more than one package are skipped or
+// reported as an error depending on the options. When adding the vanity import to a single
+// canonical file per package, files holding a wrong vanity import are fixed and packages
+// with no vanity import get it in the canonical file. When checking conflicts, files whose
+// vanity import differs from the ones in other files of the same package are reported as
+// conflicting.
+func findAndAddVanityImportForPackageFiles(workingDir, absDir string, fileNames []string, moduleName string, opts Options) (int, error) {
+	moduleName = opts.vanityImportPath(moduleName)
+
+	var errs []error
+	packages, err := readPackageFiles(workingDir, absDir, fileNames, moduleName, opts, &errs)
+	if err != nil {
+		return 0, err
+	}
+
+	packageNames := slices.Sorted(maps.Keys(packages))
+	if len(packageNames) > 1 {
+		if opts.FailOnMultiplePackages {
+			return 0, joinErrors(append(errs, fmt.Errorf("found multiple packages in %q: %s", absDir, strings.Join(packageNames, ", ")))...)
+		}
+
+		// unlike other skips, this one is always warned about as it most likely is a mistake,
+		// on stderr to not mix it with the findings
+		changed := false
+		for _, packageName := range packageNames {
+			for _, f := range packages[packageName] {
+				if !opts.isChanged(f.absFilepath) {
+					continue
 				}
-			} else {
-				// if not, we add the folder name to the vanity import
-				if c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDir+pathSeparator+dirName, moduleName+"/"+dirName, opts); err != nil {
+				changed = true
+				if err := explainSkip(opts, workingDir, f.absFilepath, moduleName, SkipMultiplePackages); err != nil {
 					return 0, err
 				}
 			}
+		}
+		if changed {
+			log.Printf("skipping %q as it holds multiple packages: %s", absDir, strings.Join(packageNames, ", "))
+		}
+		return 0, joinErrors(errs...)
+	}
 
-			gc += c
-		} else if fileName := f.Name(); isGoFile(fileName) && !isGoTestFile(fileName) {
-			if !shouldEvaluate(opts, fileName) {
-				continue
-			}
+	gc := 0
+	for _, packageName := range packageNames {
+		ignored := opts.hasPackageIgnoreDirective(absDir, packageName)
+		c, err := processPackageFiles(workingDir, packageName, moduleName, packages[packageName], ignored, opts, &errs)
+		if err != nil {
+			return 0, err
+		}
+		gc += c
+	}
 
-			absFilepath := absDir + pathSeparator + fileName
+	return gc, joinErrors(errs...)
+}
 
-			hasChanged, newContent, err := addImportPath(absDir+pathSeparator+fileName, moduleName)
-			if !hasChanged {
-				continue
+// readPackageFiles reads the given go files of a directory grouped by package. The files
+// that can't hold a vanity import are reported as skipped.
+func readPackageFiles(
+	workingDir, absDir string, fileNames []string, moduleName string, opts Options, errs *[]error,
+) (map[string][]packageFile, error) {
+	packages := map[string][]packageFile{}
+	for _, fileName := range fileNames {
+		absFilepath := absDir + pathSeparator + fileName
+		content, err := opts.readFile(absFilepath)
+		if err != nil {
+			if err := opts.handleError(errs, fmt.Errorf("failed to read the file %q: %v", absFilepath, err)); err != nil {
+				return nil, err
 			}
+			continue
+		}
 
-			switch err {
-			case nil:
-				err = handleNilErrorCase(opts, absFilepath, newContent, workingDir)
-				if err != nil {
-					return 0, err
+		fset, pf, err := parseGoFile(absFilepath, content)
+		if err != nil {
+			if err := explainParseError(opts, workingDir, absFilepath, moduleName, err); err != nil {
+				if err := opts.handleError(errs, err); err != nil {
+					return nil, err
 				}
-				gc++
-			case errMainPackage:
+			}
+			continue
+		}
+
+		packages[pf.Name.Name] = append(packages[pf.Name.Name], packageFile{
+			name:        fileName,
+			absFilepath: absFilepath,
+			content:     content,
+			clause:      newPackageClause(fset, pf),
+		})
+	}
+
+	return packages, nil
+}
+
+// processPackageFiles adds or removes the vanity import of the files of a package, skipping
+// them all when the package is ignored. It returns the number of files changed.
+func processPackageFiles(workingDir, packageName, moduleName string, files []packageFile, ignored bool, opts Options, errs *[]error) (int, error) {
+	if ignored {
+		for _, f := range files {
+			if !opts.isChanged(f.absFilepath) {
 				continue
-			default:
-				return 0, fmt.Errorf("failed to add vanity import path to %q: %v", absDir+pathSeparator+fileName, err)
 			}
+			if err := explainSkip(opts, workingDir, f.absFilepath, moduleName, SkipIgnoreDirective); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+
+	importPaths := map[string]struct{}{}
+	for _, f := range files {
+		if f.clause.importComment != "" {
+			importPaths[importPathFromComment(f.clause.importComment)] = struct{}{}
+		}
+	}
+	hasConflicts := opts.CheckConflicts && len(importPaths) > 1
+
+	gc := 0
+	canonical := canonicalFile(packageName, files)
+	for _, f := range files {
+		if !opts.isChanged(f.absFilepath) {
+			// the file was only read to know about the rest of the package
+			continue
+		}
+
+		if opts.SingleFilePerPackage && !opts.RemoveVanityImport &&
+			f.clause.importComment == "" && (len(importPaths) > 0 || f.name != canonical) {
+			if err := explainSkip(opts, workingDir, f.absFilepath, moduleName, SkipNotCanonicalFile); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		e := Event{ModulePath: moduleName}
+		if hasConflicts && f.clause.importComment != "" {
+			e.Conflicting = importPathFromComment(f.clause.importComment) != moduleName
+		}
+
+		hasChanged, err := processGoFile(workingDir, f.absFilepath, f.content, opts, e)
+		if err != nil {
+			if err := opts.handleError(errs, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if hasChanged {
+			gc++
 		}
 	}
 
 	return gc, nil
 }
 
-func shouldEvaluate(opts Options, fileName string) bool {
-	shouldEvaluate := true
+// canonicalFile returns the name of the file that should hold the vanity import of a
+// package, preferring doc.go, then the file named after the package and then the first
+// one alphabetically.
+func canonicalFile(packageName string, files []packageFile) string {
+	canonical := files[0].name
+	for _, f := range files {
+		if f.name == "doc.go" {
+			return f.name
+		} else if f.name == packageName+".go" {
+			canonical = f.name
+		}
+	}
+	return canonical
+}
+
+// fileSkipReason returns the reason for skipping a go file, empty if it should be evaluated.
+func fileSkipReason(opts Options, fileName string) SkipReason {
+	if isGoTestFile(fileName) {
+		return SkipTestFile
+	}
+
 	if len(opts.RestrictToFilesRegexes) > 0 {
-		shouldEvaluate = matchesAny(opts.RestrictToFilesRegexes, fileName)
-	} else if len(opts.SkipFilesRegexes) > 0 {
-		shouldEvaluate = !matchesAny(opts.SkipFilesRegexes, fileName)
+		if !matchesAny(opts.RestrictToFilesRegexes, fileName) {
+			return SkipNotInRestrictedFiles
+		}
+	} else if len(opts.SkipFilesRegexes) > 0 && matchesAny(opts.SkipFilesRegexes, fileName) {
+		return SkipMatchesSkipFiles
 	}
 
-	return shouldEvaluate
+	return ""
 }
 
-func handleNilErrorCase(opts Options, absFilepath string, newContent []byte, workingDir string) error {
-	if opts.WriteResultToFile {
-		err := writeContentToFile(absFilepath, newContent)
+// dirSkipReason returns the reason for skipping a directory, empty if it should be walked.
+// The regexes match its path relative to the directory of the config file they were set
+// in, or to the base directory otherwise.
+func dirSkipReason(opts Options, baseAbsDir, absDir string) (SkipReason, error) {
+	if isUnexportedDir(filepath.Base(absDir), opts.IncludeInternal) {
+		return SkipUnexportedDir, nil
+	}
+
+	if len(opts.RestrictToDirsRegexes) > 0 {
+		relDir, err := filepath.Rel(cmp.Or(opts.restrictToDirsBaseDir, baseAbsDir), absDir)
 		if err != nil {
-			return fmt.Errorf("failed to write file: %v", err)
+			return "", fmt.Errorf("failed to resolve relative path: %v", err)
 		}
-	} else if opts.ListDiffFiles {
-		relFilepath, err := filepath.Rel(workingDir, absFilepath)
+		if !matchesAny(opts.RestrictToDirsRegexes, relDir) {
+			return SkipNotInRestrictedDirs, nil
+		}
+	}
+
+	if len(opts.SkipDirsRegexes) > 0 {
+		relDir, err := filepath.Rel(cmp.Or(opts.skipDirsBaseDir, baseAbsDir), absDir)
 		if err != nil {
-			return fmt.Errorf("failed to resolve relative path: %v", err)
+			return "", fmt.Errorf("failed to resolve relative path: %v", err)
 		}
-		// TODO(jcchavezs): make this pluggable to allow different output formats
-		// and test assertions.
-		fmt.Printf("%s: missing right vanity import\n", relFilepath)
-	} else {
-		relFilepath, err := filepath.Rel(workingDir, absFilepath)
+		if matchesAny(opts.SkipDirsRegexes, relDir) {
+			return SkipMatchesSkipDirs, nil
+		}
+	}
+
+	return "", nil
+}
+
+// moduleDirSkipReason returns the reason for skipping a directory targeted within a module,
+// checking the directories from the root of the module down to it as if they were walked,
+// empty if it should be walked.
+func moduleDirSkipReason(opts Options, moduleDir, absDir string) (SkipReason, error) {
+	rel, err := filepath.Rel(moduleDir, absDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve relative path: %v", err)
+	}
+	if rel == "." {
+		return "", nil
+	}
+
+	dir := moduleDir
+	for _, dirName := range strings.Split(rel, pathSeparator) {
+		dir += pathSeparator + dirName
+		if reason, err := dirSkipReason(opts, moduleDir, dir); err != nil || reason != "" {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+// explain reports a file that porto is not going to change when running in explain mode.
+func explain(opts Options, workingDir, absPath string, e Event, action Action) error {
+	if !opts.Explain {
+		return nil
+	}
+
+	return report(opts, workingDir, absPath, e, action)
+}
+
+// report reports a file that porto is not going to change.
+func report(opts Options, workingDir, absPath string, e Event, action Action) error {
+	relPath, err := filepath.Rel(workingDir, absPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %v", err)
+	}
+	e.Path = relPath
+	e.Action = action
+
+	if err := opts.reporter().Report(e); err != nil {
+		return fmt.Errorf("failed to report %q: %v", relPath, err)
+	}
+	return nil
+}
+
+// explainSkip reports a skipped file or directory when running in explain mode.
+func explainSkip(opts Options, workingDir, absPath, moduleName string, reason SkipReason) error {
+	return explain(opts, workingDir, absPath, Event{ModulePath: moduleName, SkipReason: reason}, ActionSkipped)
+}
+
+func handleNilErrorCase(opts Options, absFilepath string, newContent []byte, workingDir string, e Event) error {
+	relFilepath, err := filepath.Rel(workingDir, absFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve relative path: %v", err)
+	}
+	e.Path = relFilepath
+	e.Content = newContent
+
+	if opts.WriteResultToFile {
+		err := writeContentToFile(absFilepath, e.OriginalContent, newContent)
 		if err != nil {
-			return fmt.Errorf("failed to resolve relative path: %v", err)
+			return fmt.Errorf("failed to write file: %v", err)
 		}
-		fmt.Printf("👉 %s\n\n", relFilepath)
-		fmt.Println(string(newContent))
+		e.Action = ActionWritten
+	} else if opts.ListDiffFiles {
+		e.Action = ActionListed
+	} else if opts.DisplayDiff {
+		e.Action = ActionDiffed
+	} else {
+		e.Action = ActionPreviewed
+	}
+
+	if err := opts.reporter().Report(e); err != nil {
+		return fmt.Errorf("failed to report %q: %v", relFilepath, err)
 	}
 	return nil
 }
@@ -218,19 +783,34 @@ func matchesAny(regexes []*regexp.Regexp, str string) bool {
 }
 
 func findAndAddVanityImportForNonModuleDir(workingDir, baseAbsDir, absDir string, opts Options) (int, error) {
-	files, err := os.ReadDir(absDir)
+	opts, err := opts.withDirConfig(absDir)
+	if err != nil {
+		return 0, err
+	}
+
+	files, err := opts.readDir(absDir)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read %q: %v", absDir, err)
 	}
 
-	gc := 0
+	var (
+		gc   int
+		errs []error
+	)
 	for _, f := range files {
 		if !f.IsDir() {
 			continue
 		}
 
 		dirName := f.Name()
+		if !opts.hasChangesIn(absDir + pathSeparator + dirName) {
+			continue
+		}
+
 		if isUnexportedDir(dirName, opts.IncludeInternal) {
+			if err := explainSkip(opts, workingDir, absDir+pathSeparator+dirName, "", SkipUnexportedDir); err != nil {
+				return 0, err
+			}
 			continue
 		}
 
@@ -240,12 +820,13 @@ func findAndAddVanityImportForNonModuleDir(workingDir, baseAbsDir, absDir string
 		)
 
 		absDirName := absDir + pathSeparator + dirName
-		if moduleName, ok := findGoModule(absDirName); ok {
-			if c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, dirName, moduleName, opts); err != nil {
-				return 0, err
-			}
+		if moduleName, ok := opts.findGoModule(absDirName); ok {
+			c, err = findAndAddVanityImportForModuleDir(workingDir, baseAbsDir, absDirName, moduleName, opts)
 		} else {
-			if c, err = findAndAddVanityImportForNonModuleDir(workingDir, baseAbsDir, absDirName, opts); err != nil {
+			c, err = findAndAddVanityImportForNonModuleDir(workingDir, baseAbsDir, absDirName, opts)
+		}
+		if err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
 				return 0, err
 			}
 		}
@@ -253,7 +834,7 @@ func findAndAddVanityImportForNonModuleDir(workingDir, baseAbsDir, absDir string
 		gc += c
 	}
 
-	return gc, nil
+	return gc, joinErrors(errs...)
 }
 
 // Options represents the options for adding vanity import.
@@ -262,6 +843,26 @@ type Options struct {
 	WriteResultToFile bool
 	// List files to be changed
 	ListDiffFiles bool
+	// Display diffs instead of the new content
+	DisplayDiff bool
+	// Remove vanity imports instead of adding them
+	RemoveVanityImport bool
+	// Build context used to skip the files excluded by build constraints or file name
+	// suffixes, nil to evaluate all files
+	BuildContext *build.Context
+	// Skip files that are only built when passing the ignore tag
+	SkipIgnoredFiles bool
+	// Add the vanity import to a single file per package, preferring doc.go, then
+	// the file named after the package and then the first one alphabetically
+	SingleFilePerPackage bool
+	// Fail when a directory holds more than one package instead of skipping it
+	FailOnMultiplePackages bool
+	// Report files whose vanity import conflicts with the ones in other files of
+	// the same package
+	CheckConflicts bool
+	// Report also the files left unchanged and the files and directories skipped
+	// along with the reason
+	Explain bool
 	// Set of regex for matching files to be skipped
 	SkipFilesRegexes []*regexp.Regexp
 	// Set of regex for matching directories to be skipped
@@ -272,21 +873,305 @@ type Options struct {
 	RestrictToFilesRegexes []*regexp.Regexp
 	// Set of regex for matching dirs to be included
 	RestrictToDirsRegexes []*regexp.Regexp
+	// Receives the result for every file whose vanity import differs, defaults
+	// to a text reporter printing to stdout
+	Reporter Reporter
+	// Process exactly the modules listed in the go.work file found in the target
+	// directory or any of its parents
+	UseWorkspace bool
+	// Keep processing the rest of the files after a failure, returning all the
+	// errors as a MultiError at the end
+	KeepGoing bool
+	// Number of directories evaluated concurrently, the results are reported in
+	// the same order regardless. Values lower than 2 evaluate them sequentially
+	Concurrency int
+	// Absolute paths of the only files to be processed, e.g. the ones changed in a git
+	// repository. The rest of the files are only read to know about their package. Nil
+	// means all the files are processed
+	ChangedFiles []string
+	// Apply the config files found from the root of the repository down to the
+	// walked directories, each one overriding the settings for its subtree
+	UseConfigFiles bool
+	// Settings taking precedence over the ones in config files, e.g. given as flags
+	ConfigOverrides *Config
+	// Vanity import path prefixes by module path prefix, for packages served under a
+	// vanity domain different from the module path, e.g. during migrations
+	VanityPaths map[string]string
+	// Style of the vanity imports written, one of ImportCommentStyles. Empty keeps the
+	// style of the current vanity imports and writes line comments otherwise
+	ImportCommentStyle string
+
+	scheduler         *scheduler
+	skipNestedModules bool
+	nonRecursive      bool
+	changedFiles      map[string]struct{}
+	config            Config
+	fsys              fs.FS
+	// directories the regexes set in config files are relative to, empty meaning
+	// the walked one
+	skipDirsBaseDir       string
+	restrictToDirsBaseDir string
+}
+
+// handleError returns the error right away unless keeping going after failures, in
+// which case it is collected to be returned once all the files were processed.
+func (o Options) handleError(errs *[]error, err error) error {
+	if !o.KeepGoing {
+		return err
+	}
+
+	*errs = append(*errs, err)
+	return nil
+}
+
+// vanityImportPath returns the import path of a package to be used in its vanity import,
+// replacing the longest matching module path prefix in VanityPaths.
+func (o Options) vanityImportPath(importPath string) string {
+	var modulePrefix string
+	for prefix := range o.VanityPaths {
+		if (importPath == prefix || strings.HasPrefix(importPath, prefix+"/")) && len(prefix) > len(modulePrefix) {
+			modulePrefix = prefix
+		}
+	}
+	if modulePrefix == "" {
+		return importPath
+	}
+
+	return o.VanityPaths[modulePrefix] + strings.TrimPrefix(importPath, modulePrefix)
+}
+
+// isChanged tells whether a file is to be processed when restricting to the changed files.
+func (o Options) isChanged(absFilepath string) bool {
+	if o.changedFiles == nil {
+		return true
+	}
+
+	_, ok := o.changedFiles[o.resolvePath(absFilepath)]
+	return ok
+}
+
+// hasChangesIn tells whether a directory holds any file to be processed when restricting
+// to the changed files, either directly or in its subdirectories.
+func (o Options) hasChangesIn(absDir string) bool {
+	if o.changedFiles == nil {
+		return true
+	}
+
+	absDir = o.resolvePath(absDir)
+	for absFilepath := range o.changedFiles {
+		if strings.HasPrefix(absFilepath, absDir+pathSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath resolves the symlinks of a path on disk so it can be compared with the
+// changed files regardless of how the directories were reached, e.g. through a symlinked
+// working dir.
+func (o Options) resolvePath(path string) string {
+	if o.fsys != nil {
+		return path
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+func (o Options) reporter() Reporter {
+	if o.Reporter == nil {
+		return NewTextReporter(os.Stdout)
+	}
+	return o.Reporter
 }
 
 // FindAndAddVanityImportForDir scans all files in a folder and based on go.mod files
 // encountered decides wether add a vanity import or not.
 func FindAndAddVanityImportForDir(workingDir, absDir string, opts Options) (int, error) {
-	if moduleName, ok := findGoModule(absDir); ok {
+	return run(opts, func(opts Options) (int, error) {
+		return findAndAddVanityImportForDir(workingDir, absDir, opts)
+	})
+}
+
+// FindAndAddVanityImportForContent adds the vanity import to the content of a go file
+// which may not be saved to disk yet, e.g. an editor buffer. The import path is based
+// on the module the given file path belongs to. The content is returned as is for the
+// files porto skips. When adding the vanity import to a single file per package, the rest
+// of the files of the package are read from disk to tell whether the given one holds it,
+// the other options applying to whole packages are ignored.
+func FindAndAddVanityImportForContent(absFilepath string, content []byte, opts Options) ([]byte, error) {
+	absDir, fileName := filepath.Dir(absFilepath), filepath.Base(absFilepath)
+
+	opts, err := opts.withConfigsFor(absDir)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleDir, moduleName, ok := opts.importPathOf(absDir)
+	if !ok {
+		return content, nil
+	}
+
+	if reason, err := moduleDirSkipReason(opts, moduleDir, absDir); err != nil {
+		return nil, err
+	} else if reason != "" {
+		return content, nil
+	}
+
+	if isUnexportedModule(moduleName, opts.IncludeInternal) || fileSkipReason(opts, fileName) != "" {
+		return content, nil
+	}
+
+	reason, err := buildSkipReason(opts, absDir, fileName, content)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return content, nil
+	}
+
+	if fileName != "doc.go" {
+		pf, err := parser.ParseFile(token.NewFileSet(), absFilepath, content, parser.PackageClauseOnly)
+		if err == nil && opts.hasPackageIgnoreDirective(absDir, pf.Name.Name) {
+			return content, nil
+		}
+	}
+
+	if opts.SingleFilePerPackage && !opts.RemoveVanityImport {
+		if ok, err := isCanonicalContent(absFilepath, content, opts); err != nil {
+			return nil, err
+		} else if !ok {
+			return content, nil
+		}
+	}
+
+	var newContent []byte
+	if opts.RemoveVanityImport {
+		_, newContent, _, err = removeImportPathFromContent(absFilepath, content)
+	} else {
+		_, newContent, _, _, err = addImportPathToContent(absFilepath, content, opts.vanityImportPath(moduleName), opts.ImportCommentStyle)
+	}
+	if newContent, err = contentOrSkipped(content, newContent, err); err != nil {
+		return nil, fmt.Errorf("failed to add vanity import path to %q: %v", absFilepath, err)
+	}
+	return newContent, nil
+}
+
+// isCanonicalContent tells whether the content of a go file is to hold the vanity import
+// when adding it to a single file per package, reading the rest of the files of its package
+// from its directory as the walk does. The files that can't be parsed are left out.
+func isCanonicalContent(absFilepath string, content []byte, opts Options) (bool, error) {
+	absDir, fileName := filepath.Dir(absFilepath), filepath.Base(absFilepath)
+
+	fset, pf, err := parseGoFile(absFilepath, content)
+	if err != nil {
+		// the file is skipped or fails later on
+		return true, nil
+	}
+	clause := newPackageClause(fset, pf)
+	if clause.importComment != "" {
+		// the current vanity import gets fixed
+		return true, nil
+	}
+	packageName := pf.Name.Name
+	files := []packageFile{{name: fileName, clause: clause}}
+
+	entries, err := opts.readDir(absDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read the content of %q: %v", absDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isGoFile(name) || name == fileName || fileSkipReason(opts, name) != "" {
+			continue
+		}
+		if reason, err := buildSkipReason(opts, absDir, name, nil); err != nil || reason != "" {
+			continue
+		}
+
+		siblingFilepath := absDir + pathSeparator + name
+		siblingContent, err := opts.readFile(siblingFilepath)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the file %q: %v", siblingFilepath, err)
+		}
+		siblingFset, siblingPf, err := parseGoFile(siblingFilepath, siblingContent)
+		if err != nil || siblingPf.Name.Name != packageName {
+			continue
+		}
+		files = append(files, packageFile{name: name, clause: newPackageClause(siblingFset, siblingPf)})
+	}
+	slices.SortFunc(files, func(a, b packageFile) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	for _, f := range files {
+		if f.clause.importComment != "" {
+			return false, nil
+		}
+	}
+	return canonicalFile(packageName, files) == fileName, nil
+}
+
+// run walks using fn, evaluating the directories concurrently when requested.
+func run(opts Options, fn func(Options) (int, error)) (int, error) {
+	if opts.ChangedFiles != nil {
+		opts.changedFiles = make(map[string]struct{}, len(opts.ChangedFiles))
+		for _, absFilepath := range opts.ChangedFiles {
+			opts.changedFiles[opts.resolvePath(filepath.Clean(absFilepath))] = struct{}{}
+		}
+	}
+
+	if opts.Concurrency < 2 {
+		return fn(opts)
+	}
+
+	r := opts.reporter()
+	s := newScheduler(opts.Concurrency, opts.KeepGoing)
+	opts.Reporter, opts.scheduler = s, s
+
+	gc, walkErr := fn(opts)
+	c, err := s.flush(r)
+	if !opts.KeepGoing && (err != nil || walkErr != nil) {
+		// the failures of the scheduled work come first in the order of the walk
+		return 0, cmp.Or(err, walkErr)
+	}
+	return gc + c, joinErrors(walkErr, err)
+}
+
+func findAndAddVanityImportForDir(workingDir, absDir string, opts Options) (int, error) {
+	if opts.UseWorkspace {
+		return findAndAddVanityImportForWorkspace(workingDir, absDir, opts)
+	}
+
+	opts, err := opts.withConfigsFor(absDir)
+	if err != nil {
+		return 0, err
+	}
+
+	// when targeting a subdirectory of a module, the import path is based on the
+	// nearest go.mod in its parents
+	if moduleDir, moduleName, ok := opts.importPathOf(absDir); ok {
+		reason, err := moduleDirSkipReason(opts, moduleDir, absDir)
+		if err != nil {
+			return 0, err
+		}
+		if reason != "" {
+			return 0, explainSkip(opts, workingDir, absDir, moduleName, reason)
+		}
 		return findAndAddVanityImportForModuleDir(workingDir, absDir, absDir, moduleName, opts)
 	}
 
-	files, err := os.ReadDir(absDir)
+	files, err := opts.readDir(absDir)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read the content of %q: %v", absDir, err)
 	}
 
-	gc := 0
+	var (
+		gc   int
+		errs []error
+	)
 	for _, f := range files {
 		if !f.IsDir() {
 			// we already knew this is not a Go modules folder hence we are not looking
@@ -295,7 +1180,14 @@ func FindAndAddVanityImportForDir(workingDir, absDir string, opts Options) (int,
 		}
 
 		dirName := f.Name()
+		if !opts.hasChangesIn(absDir + pathSeparator + dirName) {
+			continue
+		}
+
 		if isUnexportedDir(dirName, opts.IncludeInternal) {
+			if err := explainSkip(opts, workingDir, absDir+pathSeparator+dirName, "", SkipUnexportedDir); err != nil {
+				return 0, err
+			}
 			continue
 		}
 
@@ -304,12 +1196,63 @@ func FindAndAddVanityImportForDir(workingDir, absDir string, opts Options) (int,
 			err error
 		)
 		absDirName := absDir + pathSeparator + dirName
-		if moduleName, ok := findGoModule(absDirName); ok {
-			if c, err = findAndAddVanityImportForModuleDir(workingDir, absDir, dirName, moduleName, opts); err != nil {
+		if moduleName, ok := opts.findGoModule(absDirName); ok {
+			c, err = findAndAddVanityImportForModuleDir(workingDir, absDir, absDirName, moduleName, opts)
+		} else {
+			c, err = findAndAddVanityImportForNonModuleDir(workingDir, absDir, absDirName, opts)
+		}
+		if err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
 				return 0, err
 			}
-		} else {
-			if c, err = findAndAddVanityImportForNonModuleDir(workingDir, absDir, absDirName, opts); err != nil {
+		}
+
+		gc += c
+	}
+
+	return gc, joinErrors(errs...)
+}
+
+// findAndAddVanityImportForWorkspace adds the vanity import to exactly the modules listed
+// in the go.work file found in the given directory or any of its parents.
+func findAndAddVanityImportForWorkspace(workingDir, absDir string, opts Options) (int, error) {
+	goWork, ok := findGoWork(absDir)
+	if !ok {
+		return 0, fmt.Errorf("failed to find a go.work file in %q or any of its parents", absDir)
+	}
+
+	moduleDirs, err := readGoWorkModules(goWork)
+	if err != nil {
+		return 0, err
+	}
+
+	opts.skipNestedModules = true
+
+	var (
+		gc   int
+		errs []error
+	)
+	for _, moduleDir := range moduleDirs {
+		moduleName, ok := findGoModule(moduleDir)
+		if !ok {
+			err := fmt.Errorf("failed to find the go.mod file of %q used in %q", moduleDir, goWork)
+			if err := opts.handleError(&errs, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		moduleOpts, err := opts.withConfigsFor(moduleDir)
+		if err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		c, err := findAndAddVanityImportForModuleDir(workingDir, moduleDir, moduleDir, moduleName, moduleOpts)
+		if err != nil {
+			if err := opts.handleError(&errs, err); err != nil {
 				return 0, err
 			}
 		}
@@ -317,5 +1260,5 @@ func FindAndAddVanityImportForDir(workingDir, absDir string, opts Options) (int,
 		gc += c
 	}
 
-	return gc, nil
+	return gc, joinErrors(errs...)
 }
@@ -1,6 +1,8 @@
 package porto
 
 import (
+	"bytes"
+	"log"
 	"os"
 	"regexp"
 	"testing"
@@ -11,18 +13,20 @@ import (
 
 func TestAddImportPathAddsVanityImport(t *testing.T) {
 	cwd, _ := os.Getwd()
-	hasChanged, newContent, err := addImportPath(
+	hasChanged, newContent, clause, err := addImportPath(
 		cwd+"/testdata/leftpad/leftpad.go",
 		"mypackage")
 
 	require.NoError(t, err)
 	assert.True(t, hasChanged)
+	assert.Empty(t, clause.importComment)
+	assert.Equal(t, 3, clause.line)
 	assert.Equal(t, "package leftpad // import \"mypackage\"", string(newContent[15:52]))
 }
 
 func TestAddImportAutogenerated(t *testing.T) {
 	cwd, _ := os.Getwd()
-	hasChanged, _, err := addImportPath(
+	hasChanged, _, _, err := addImportPath(
 		cwd+"/testdata/codegen/generated.go",
 		"codegen")
 
@@ -32,15 +36,291 @@ func TestAddImportAutogenerated(t *testing.T) {
 
 func TestAddImportPathFixesTheVanityImport(t *testing.T) {
 	cwd, _ := os.Getwd()
-	hasChanged, newContent, err := addImportPath(
+	hasChanged, newContent, clause, err := addImportPath(
 		cwd+"/testdata/rightpad/rightpad.go",
 		"mypackage")
 
 	require.NoError(t, err)
 	assert.True(t, hasChanged)
+	assert.Equal(t, "// import \"wrong/import/rightpad\"", clause.importComment)
 	assert.Equal(t, "package rightpad // import \"mypackage\"", string(newContent[:38]))
 }
 
+type recordingReporter struct {
+	events []Event
+}
+
+func (r *recordingReporter) Report(e Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestAddImportPathToContentKeepsCRLF(t *testing.T) {
+	hasChanged, newContent, _, _, err := addImportPathToContent("pad.go", []byte("package pad\r\n\r\nvar a = 1\r\n"), "pad", "")
+
+	require.NoError(t, err)
+	assert.True(t, hasChanged)
+	assert.Equal(t, "package pad // import \"pad\"\r\n\r\nvar a = 1\r\n", string(newContent))
+}
+
+func TestAddImportPathToContentPackageClauses(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "tab after the package keyword",
+			content:  "package\tpad\n\nvar a = 1\n",
+			expected: "package\tpad // import \"pad\"\n\nvar a = 1\n",
+		},
+		{
+			name:     "comment before the package name",
+			content:  "package /* c */ pad\n",
+			expected: "package /* c */ pad // import \"pad\"\n",
+		},
+		{
+			name:     "header comments",
+			content:  "// Copyright\n\n// Package pad pads.\npackage pad\n",
+			expected: "// Copyright\n\n// Package pad pads.\npackage pad // import \"pad\"\n",
+		},
+		{
+			name:     "no trailing newline",
+			content:  "package pad",
+			expected: "package pad // import \"pad\"",
+		},
+		{
+			name:     "no trailing newline with a vanity import",
+			content:  "package pad // import \"wrong\"",
+			expected: "package pad // import \"pad\"",
+		},
+		{
+			name:     "byte order mark",
+			content:  "\xef\xbb\xbfpackage pad\n",
+			expected: "\xef\xbb\xbfpackage pad // import \"pad\"\n",
+		},
+		{
+			name:     "CRLF with a vanity import",
+			content:  "package pad // import \"wrong\"\r\n",
+			expected: "package pad // import \"pad\"\r\n",
+		},
+		{
+			name:     "trailing whitespace",
+			content:  "package pad \t\n",
+			expected: "package pad // import \"pad\"\n",
+		},
+		{
+			name:     "block comment spanning lines",
+			content:  "package pad /* a\nb */\n\nvar a = 1\n",
+			expected: "package pad /* import \"pad\" */ /* a\nb */\n\nvar a = 1\n",
+		},
+		{
+			name:     "code following the package clause",
+			content:  "package pad; var a = 1\n",
+			expected: "package pad /* import \"pad\" */; var a = 1\n",
+		},
+		{
+			name:     "already there",
+			content:  "package pad // import \"pad\"\n",
+			expected: "package pad // import \"pad\"\n",
+		},
+		{
+			name:     "comment spanning lines before the package name",
+			content:  "package /* a\n */ pad\n",
+			expected: "package /* a\n */ pad // import \"pad\"\n",
+		},
+		{
+			name:     "wrong vanity import after a comment spanning lines",
+			content:  "package /* a\n */ pad // import \"wrong\"\n",
+			expected: "package /* a\n */ pad // import \"pad\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasChanged, newContent, _, _, err := addImportPathToContent("pad.go", []byte(tt.content), "pad", "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.content != tt.expected, hasChanged)
+			assert.Equal(t, tt.expected, string(newContent))
+
+			// adding it again leaves the file as is
+			hasChanged, _, _, _, err = addImportPathToContent("pad.go", newContent, "pad", "")
+			require.NoError(t, err)
+			assert.False(t, hasChanged)
+		})
+	}
+}
+
+func TestAddImportPathToContentTrailingComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "line comment",
+			content:  "package pad // nolint:revive\n",
+			expected: "package pad /* import \"pad\" */ // nolint:revive\n",
+		},
+		{
+			name:     "block comment",
+			content:  "package pad /* nolint */\n",
+			expected: "package pad /* import \"pad\" */ /* nolint */\n",
+		},
+		{
+			name:     "wrong line vanity import",
+			content:  "package pad // import \"wrong\"\n",
+			expected: "package pad // import \"pad\"\n",
+		},
+		{
+			name:     "wrong block vanity import",
+			content:  "package pad /* import \"wrong\" */\n",
+			expected: "package pad /* import \"pad\" */\n",
+		},
+		{
+			name:     "block vanity import followed by line comment",
+			content:  "package pad /* import \"pad\" */ // nolint:revive\n",
+			expected: "package pad /* import \"pad\" */ // nolint:revive\n",
+		},
+		{
+			name:     "wrong block vanity import followed by line comment",
+			content:  "package pad /* import \"wrong\" */ // nolint:revive\n",
+			expected: "package pad /* import \"pad\" */ // nolint:revive\n",
+		},
+		{
+			name:     "wrong block vanity import followed by block comment",
+			content:  "package pad /* import \"wrong\" */ /* nolint */\n",
+			expected: "package pad /* import \"pad\" */ /* nolint */\n",
+		},
+		{
+			name:     "block comment followed by line vanity import",
+			content:  "package pad /* nolint */ // import \"pad\"\n",
+			expected: "package pad /* import \"pad\" */ /* nolint */\n",
+		},
+		{
+			name:     "block comment followed by wrong line vanity import",
+			content:  "package pad /* nolint */ // import \"wrong\"\r\n",
+			expected: "package pad /* import \"pad\" */ /* nolint */\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasChanged, newContent, _, _, err := addImportPathToContent("pad.go", []byte(tt.content), "pad", "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.content != tt.expected, hasChanged)
+			assert.Equal(t, tt.expected, string(newContent))
+
+			// adding it again leaves the file as is
+			hasChanged, _, _, _, err = addImportPathToContent("pad.go", newContent, "pad", "")
+			require.NoError(t, err)
+			assert.False(t, hasChanged)
+		})
+	}
+}
+
+func TestAddImportPathToContentStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		style    string
+		expected string
+	}{
+		{name: "line", content: "package pad\n", style: ImportCommentLine, expected: "package pad // import \"pad\"\n"},
+		{name: "block", content: "package pad\n", style: ImportCommentBlock, expected: "package pad /* import \"pad\" */\n"},
+		{name: "line to block", content: "package pad // import \"pad\"\n", style: ImportCommentBlock, expected: "package pad /* import \"pad\" */\n"},
+		{name: "block to line", content: "package pad /* import \"pad\" */\n", style: ImportCommentLine, expected: "package pad // import \"pad\"\n"},
+		{name: "block kept", content: "package pad /* import \"pad\" */\n", expected: "package pad /* import \"pad\" */\n"},
+		{name: "line kept", content: "package pad // import \"pad\"\n", expected: "package pad // import \"pad\"\n"},
+		{name: "line followed by comment", content: "package pad // nolint\n", style: ImportCommentLine, expected: "package pad /* import \"pad\" */ // nolint\n"},
+		{name: "block followed by comment", content: "package pad // nolint\n", style: ImportCommentBlock, expected: "package pad /* import \"pad\" */ // nolint\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasChanged, newContent, _, importComment, err := addImportPathToContent("pad.go", []byte(tt.content), "pad", tt.style)
+			require.NoError(t, err)
+			assert.Equal(t, tt.content != tt.expected, hasChanged)
+			assert.Equal(t, tt.expected, string(newContent))
+			assert.Contains(t, tt.expected, "package pad "+importComment)
+		})
+	}
+}
+
+func TestAddImportPathToContentIgnoreDirective(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		ignored bool
+	}{
+		"directive":                   {content: "//porto:ignore\n\npackage pad\n", ignored: true},
+		"directive with reason":       {content: "// Copyright\n\n//porto:ignore served from a different host\npackage pad\n", ignored: true},
+		"directive in doc comment":    {content: "// Package pad pads.\n//\n//porto:ignore\npackage pad\n", ignored: true},
+		"directive after the clause":  {content: "package pad\n\n//porto:ignore\nvar a = 1\n"},
+		"not the directive":           {content: "//porto:ignored\npackage pad\n"},
+		"directive with leading text": {content: "// see //porto:ignore\npackage pad\n"},
+	}
+	for name, tCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, _, err := addImportPathToContent("pad.go", []byte(tCase.content), "pad", "")
+			if tCase.ignored {
+				assert.ErrorIs(t, err, errIgnored)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, _, _, err = removeImportPathFromContent("pad.go", []byte(tCase.content))
+			if tCase.ignored {
+				assert.ErrorIs(t, err, errIgnored)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRemoveImportPathFromContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		hasChanged bool
+		expected   string
+	}{
+		{
+			name:       "line comment",
+			content:    "package rightpad // import \"wrong/import/rightpad\"\n\nvar a = 1\n",
+			hasChanged: true,
+			expected:   "package rightpad\n\nvar a = 1\n",
+		},
+		{
+			name:       "block comment followed by other comment",
+			content:    "package rightpad /* import \"rightpad\" */ // nolint\n",
+			hasChanged: true,
+			expected:   "package rightpad // nolint\n",
+		},
+		{
+			name:       "other comment",
+			content:    "package rightpad // nolint\n",
+			hasChanged: false,
+			expected:   "package rightpad // nolint\n",
+		},
+		{
+			name:       "no comment",
+			content:    "// import \"rightpad\"\npackage rightpad\n",
+			hasChanged: false,
+			expected:   "// import \"rightpad\"\npackage rightpad\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasChanged, newContent, _, err := removeImportPathFromContent("rightpad.go", []byte(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.hasChanged, hasChanged)
+			assert.Equal(t, tt.expected, string(newContent))
+		})
+	}
+}
+
 func TestFindFilesWithVanityImport(t *testing.T) {
 	cwd, _ := os.Getwd()
 
@@ -59,6 +339,50 @@ func TestFindFilesWithVanityImport(t *testing.T) {
 		assert.Equal(t, 2, c)
 	})
 
+	t.Run("events reported", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			cwd,
+			cwd+"/testdata/rightpad",
+			cwd+"/testdata/rightpad",
+			"jcchavezs.github.io/porto/integration/rightpad",
+			Options{
+				ListDiffFiles: true,
+				Reporter:      r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "testdata/rightpad/rightpad.go", r.events[0].Path)
+		assert.Equal(t, 1, r.events[0].Line)
+		assert.Equal(t, 1, r.events[0].Column)
+		assert.Equal(t, "jcchavezs.github.io/porto/integration/rightpad", r.events[0].ModulePath)
+		assert.Equal(t, "// import \"wrong/import/rightpad\"", r.events[0].OldImportComment)
+		assert.Equal(t, "// import \"jcchavezs.github.io/porto/integration/rightpad\"", r.events[0].NewImportComment)
+		assert.Equal(t, ActionListed, r.events[0].Action)
+	})
+
+	t.Run("events report the vanity import written", func(t *testing.T) {
+		r := &recordingReporter{}
+		_, err := findAndAddVanityImportForModuleDir(
+			cwd,
+			cwd+"/testdata/rightpad",
+			cwd+"/testdata/rightpad",
+			"jcchavezs.github.io/porto/integration/rightpad",
+			Options{
+				ListDiffFiles:      true,
+				ImportCommentStyle: ImportCommentBlock,
+				Reporter:           r,
+			},
+		)
+
+		require.NoError(t, err)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "/* import \"jcchavezs.github.io/porto/integration/rightpad\" */", r.events[0].NewImportComment)
+	})
+
 	t.Run("no files listed", func(t *testing.T) {
 		c, err := findAndAddVanityImportForModuleDir(
 			cwd,
@@ -74,6 +398,197 @@ func TestFindFilesWithVanityImport(t *testing.T) {
 		assert.Equal(t, 0, c)
 	})
 
+	t.Run("remove vanity import", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			cwd,
+			cwd+"/testdata",
+			cwd+"/testdata",
+			"github.com/jcchavezs/porto/integration",
+			Options{
+				ListDiffFiles:      true,
+				RemoveVanityImport: true,
+				Reporter:           r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, c)
+		require.Len(t, r.events, 2)
+		assert.Equal(t, "testdata/nopad/nopad.go", r.events[0].Path)
+		assert.Equal(t, "testdata/rightpad/rightpad.go", r.events[1].Path)
+		assert.Empty(t, r.events[1].NewImportComment)
+		assert.Equal(t, "package rightpad\n", string(r.events[1].Content[:17]))
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			cwd,
+			cwd+"/testdata",
+			cwd+"/testdata",
+			"github.com/jcchavezs/porto/integration",
+			Options{
+				ListDiffFiles:    true,
+				Explain:          true,
+				SkipFilesRegexes: []*regexp.Regexp{regexp.MustCompile(`^other\.go$`)},
+				SkipDirsRegexes:  []*regexp.Regexp{regexp.MustCompile(`^withoutgomod$`)},
+				Reporter:         r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, c)
+
+		actions := map[string]string{}
+		for _, e := range r.events {
+			actions[e.Path] = string(e.Action) + " " + string(e.SkipReason)
+		}
+		assert.Equal(t, map[string]string{
+			"testdata/codegen/generated.go":      "skipped generated file",
+			"testdata/codegen/user.pb.go":        "listed ",
+			"testdata/doc.go":                    "listed ",
+			"testdata/leftpad/leftpad.go":        "listed ",
+			"testdata/leftpad/leftpad_test.go":   "skipped test file",
+			"testdata/leftpad/other.go":          "skipped matches skip files regexes",
+			"testdata/nopad/nopad.go":            "unchanged ",
+			"testdata/rightpad/rightpad.go":      "listed ",
+			"testdata/rightpad/rightpad_test.go": "skipped test file",
+			"testdata/withoutgomod":              "skipped matches skip dirs regexes",
+		}, actions)
+	})
+
+	t.Run("single file per package", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			cwd,
+			cwd+"/testdata/leftpad",
+			cwd+"/testdata/leftpad",
+			"github.com/jcchavezs/porto-integration-leftpad",
+			Options{
+				ListDiffFiles:        true,
+				SingleFilePerPackage: true,
+				Reporter:             r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "testdata/leftpad/leftpad.go", r.events[0].Path)
+	})
+
+	t.Run("single file per package with existing vanity imports", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(dir+"/doc.go", []byte("package pad\n"), 0644))
+		require.NoError(t, os.WriteFile(dir+"/left.go", []byte("package pad // import \"pad\"\n"), 0644))
+		require.NoError(t, os.WriteFile(dir+"/right.go", []byte("package pad // import \"wrong/pad\"\n"), 0644))
+
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			dir,
+			dir,
+			dir,
+			"pad",
+			Options{
+				ListDiffFiles:        true,
+				SingleFilePerPackage: true,
+				Reporter:             r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "right.go", r.events[0].Path)
+	})
+
+	t.Run("check conflicts", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(dir+"/left.go", []byte("package pad // import \"pad\"\n"), 0644))
+		require.NoError(t, os.WriteFile(dir+"/middle.go", []byte("package pad\n"), 0644))
+		require.NoError(t, os.WriteFile(dir+"/right.go", []byte("package pad // import \"wrong/pad\"\n"), 0644))
+
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(
+			dir,
+			dir,
+			dir,
+			"pad",
+			Options{
+				ListDiffFiles:  true,
+				CheckConflicts: true,
+				Reporter:       r,
+			},
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, c)
+		require.Len(t, r.events, 2)
+		assert.Equal(t, "middle.go", r.events[0].Path)
+		assert.False(t, r.events[0].Conflicting)
+		assert.Equal(t, "right.go", r.events[1].Path)
+		assert.True(t, r.events[1].Conflicting)
+		assert.Equal(t, `// import "wrong/pad"`, r.events[1].OldImportComment)
+	})
+
+	t.Run("multiple packages", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(dir+"/pad.go", []byte("package pad\n"), 0644))
+		require.NoError(t, os.WriteFile(dir+"/example.go", []byte("package pad_test\n"), 0644))
+
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		r := &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(dir, dir, dir, "pad", Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, c)
+		assert.Empty(t, r.events)
+		assert.Contains(t, logs.String(), "skipping \""+dir+"\" as it holds multiple packages: pad, pad_test")
+
+		r = &recordingReporter{}
+		_, err = findAndAddVanityImportForModuleDir(dir, dir, dir, "pad", Options{ListDiffFiles: true, Explain: true, Reporter: r})
+
+		require.NoError(t, err)
+		require.Len(t, r.events, 2)
+		for _, e := range r.events {
+			assert.Equal(t, ActionSkipped, e.Action)
+			assert.Equal(t, SkipMultiplePackages, e.SkipReason)
+		}
+
+		_, err = findAndAddVanityImportForModuleDir(dir, dir, dir, "pad", Options{ListDiffFiles: true, FailOnMultiplePackages: true})
+		assert.EqualError(t, err, "found multiple packages in \""+dir+"\": pad, pad_test")
+	})
+
+	t.Run("keep going", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"go.mod":           "module pad\n",
+			"left/left.go":     "package left\nfunc {\n",
+			"middle/middle.go": "package middle\n",
+			"right/right.go":   "package right\nfunc {\n",
+		})
+
+		r := &recordingReporter{}
+		_, err := findAndAddVanityImportForModuleDir(dir, dir, dir, "pad", Options{ListDiffFiles: true, Reporter: r})
+		require.Error(t, err)
+		assert.Empty(t, r.events)
+
+		r = &recordingReporter{}
+		c, err := findAndAddVanityImportForModuleDir(dir, dir, dir, "pad", Options{ListDiffFiles: true, KeepGoing: true, Reporter: r})
+		var merr MultiError
+		require.ErrorAs(t, err, &merr)
+		require.Len(t, merr, 2)
+		assert.Contains(t, merr[0].Error(), dir+"/left/left.go")
+		assert.Contains(t, merr[1].Error(), dir+"/right/right.go")
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "middle/middle.go", r.events[0].Path)
+	})
+
 	t.Run("skip file", func(t *testing.T) {
 		c, err := findAndAddVanityImportForModuleDir(
 			cwd,
@@ -161,6 +676,367 @@ func TestFindFilesWithVanityImport(t *testing.T) {
 
 }
 
+func TestCanonicalFile(t *testing.T) {
+	assert.Equal(t, "doc.go", canonicalFile("pad", []packageFile{{name: "a.go"}, {name: "doc.go"}, {name: "pad.go"}}))
+	assert.Equal(t, "pad.go", canonicalFile("pad", []packageFile{{name: "a.go"}, {name: "pad.go"}}))
+	assert.Equal(t, "a.go", canonicalFile("pad", []packageFile{{name: "a.go"}, {name: "b.go"}}))
+}
+
+func TestFindAndAddVanityImportForWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"ws/go.work":              "go 1.23\n\nuse (\n\t./a\n\t../shared\n)\n",
+		"ws/a/go.mod":             "module a\n",
+		"ws/a/a.go":               "package a\n",
+		"ws/a/nested/go.mod":      "module nested\n",
+		"ws/a/nested/nested.go":   "package nested\n",
+		"ws/unlisted/go.mod":      "module unlisted\n",
+		"ws/unlisted/unlisted.go": "package unlisted\n",
+		"shared/go.mod":           "module shared\n",
+		"shared/shared.go":        "package shared\n",
+	})
+
+	r := &recordingReporter{}
+	c, err := FindAndAddVanityImportForDir(dir, dir+"/ws/a", Options{ListDiffFiles: true, UseWorkspace: true, Reporter: r})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, c)
+	require.Len(t, r.events, 2)
+	assert.Equal(t, "ws/a/a.go", r.events[0].Path)
+	assert.Equal(t, "a", r.events[0].ModulePath)
+	assert.Equal(t, "shared/shared.go", r.events[1].Path)
+	assert.Equal(t, "shared", r.events[1].ModulePath)
+
+	_, err = FindAndAddVanityImportForDir(dir, dir+"/shared", Options{ListDiffFiles: true, UseWorkspace: true, Reporter: r})
+	assert.EqualError(t, err, "failed to find a go.work file in \""+dir+"/shared\" or any of its parents")
+}
+
+func TestFindAndAddVanityImportForSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"repo/go.mod":              "module example.com/repo\n",
+		"repo/pkg/sub/sub.go":      "package sub\n",
+		"repo/pkg/sub/deep/d.go":   "package deep\n",
+		"repo/testdata/fixture.go": "package fixture\n",
+		"repo/vendor/x.io/y/y.go":  "package y\n",
+		"repo/internal/in/in.go":   "package in\n",
+		"repo/gen/pkg/pkg.go":      "package pkg\n",
+		"plain/mod/go.mod":         "module example.com/mod\n",
+		"plain/mod/mod.go":         "package mod\n",
+	})
+
+	t.Run("module path from the nearest go.mod", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir+"/repo/pkg/sub", Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, c)
+		require.Len(t, r.events, 2)
+		assert.Equal(t, "repo/pkg/sub/sub.go", r.events[0].Path)
+		assert.Equal(t, "example.com/repo/pkg/sub", r.events[0].ModulePath)
+		assert.Equal(t, "repo/pkg/sub/deep/d.go", r.events[1].Path)
+		assert.Equal(t, "example.com/repo/pkg/sub/deep", r.events[1].ModulePath)
+	})
+
+	t.Run("testdata is not part of the module", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir+"/repo/testdata", Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, c)
+		assert.Empty(t, r.events)
+	})
+
+	t.Run("vendor is not part of the module", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir+"/repo/vendor/x.io/y", Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, c)
+		assert.Empty(t, r.events)
+
+		c, err = FindAndAddVanityImportForPatterns(dir+"/repo", []string{"./vendor/..."}, Options{ListDiffFiles: true, Reporter: r})
+		require.NoError(t, err)
+		assert.Equal(t, 0, c)
+		assert.Empty(t, r.events)
+	})
+
+	t.Run("skipped directories between the module and the target", func(t *testing.T) {
+		opts := Options{
+			ListDiffFiles:   true,
+			Explain:         true,
+			SkipDirsRegexes: []*regexp.Regexp{regexp.MustCompile("^gen$")},
+		}
+		for target, reason := range map[string]SkipReason{
+			"./internal/in": SkipUnexportedDir,
+			"./gen/pkg":     SkipMatchesSkipDirs,
+			"./gen/...":     SkipMatchesSkipDirs,
+		} {
+			r := &recordingReporter{}
+			opts.Reporter = r
+			c, err := FindAndAddVanityImportForPatterns(dir+"/repo", []string{target}, opts)
+
+			require.NoError(t, err)
+			assert.Equal(t, 0, c, target)
+			require.Len(t, r.events, 1, target)
+			assert.Equal(t, reason, r.events[0].SkipReason, target)
+		}
+	})
+
+	t.Run("modules within a non module directory", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir+"/plain", Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "plain/mod/mod.go", r.events[0].Path)
+		assert.Equal(t, "example.com/mod", r.events[0].ModulePath)
+	})
+}
+
+func TestFindAndAddVanityImportForContent(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/go.mod", []byte("module example.com/repo\n"), 0644))
+
+	testCases := []struct {
+		name     string
+		path     string
+		content  string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "unsaved file",
+			path:     "/pkg/sub/new.go",
+			content:  "package sub\n\nvar A = 1\n",
+			expected: "package sub // import \"example.com/repo/pkg/sub\"\n\nvar A = 1\n",
+		},
+		{
+			name:     "remove",
+			path:     "/pkg/new.go",
+			content:  "package pkg // import \"example.com/repo/pkg\"\n",
+			opts:     Options{RemoveVanityImport: true},
+			expected: "package pkg\n",
+		},
+		{name: "main package", path: "/cmd/new.go", content: "package main\n", expected: "package main\n"},
+		{name: "test file", path: "/pkg/new_test.go", content: "package pkg\n", expected: "package pkg\n"},
+		{name: "internal dir", path: "/internal/new.go", content: "package internal\n", expected: "package internal\n"},
+		{
+			name:     "skipped dir",
+			path:     "/tools/new.go",
+			content:  "package tools\n",
+			opts:     Options{SkipDirsRegexes: []*regexp.Regexp{regexp.MustCompile("^tools$")}},
+			expected: "package tools\n",
+		},
+		{
+			name:     "build constraints",
+			path:     "/pkg/new_windows.go",
+			content:  "package pkg\n",
+			opts:     Options{BuildContext: NewBuildContext("linux", "amd64", nil)},
+			expected: "package pkg\n",
+		},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			newContent, err := FindAndAddVanityImportForContent(dir+tCase.path, []byte(tCase.content), tCase.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, string(newContent))
+		})
+	}
+
+	_, err := FindAndAddVanityImportForContent(dir+"/pkg/new.go", []byte("package\n"), Options{})
+	assert.Error(t, err)
+
+	t.Run("single file per package", func(t *testing.T) {
+		writeFiles(t, dir, map[string]string{
+			"single/doc.go":    "package single\n",
+			"single/single.go": "package single\n",
+			"held/a.go":        "package held\n",
+			"held/b.go":        "package held // import \"example.com/repo/held\"\n",
+		})
+		opts := Options{SingleFilePerPackage: true}
+
+		newContent, err := FindAndAddVanityImportForContent(dir+"/single/new.go", []byte("package single\n"), opts)
+		require.NoError(t, err)
+		assert.Equal(t, "package single\n", string(newContent))
+
+		newContent, err = FindAndAddVanityImportForContent(dir+"/single/doc.go", []byte("package single\n"), opts)
+		require.NoError(t, err)
+		assert.Equal(t, "package single // import \"example.com/repo/single\"\n", string(newContent))
+
+		newContent, err = FindAndAddVanityImportForContent(dir+"/held/a.go", []byte("package held\n"), opts)
+		require.NoError(t, err)
+		assert.Equal(t, "package held\n", string(newContent))
+
+		newContent, err = FindAndAddVanityImportForContent(dir+"/held/b.go", []byte("package held // import \"wrong\"\n"), opts)
+		require.NoError(t, err)
+		assert.Equal(t, "package held // import \"example.com/repo/held\"\n", string(newContent))
+
+		newContent, err = FindAndAddVanityImportForContent(dir+"/fresh/new.go", []byte("package fresh\n"), opts)
+		require.NoError(t, err)
+		assert.Equal(t, "package fresh // import \"example.com/repo/fresh\"\n", string(newContent))
+	})
+}
+
+func TestFindAndAddVanityImportIgnoreDirective(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                 "module example.com/repo\n",
+		"ignored/doc.go":         "//porto:ignore\n\n// Package ignored is served elsewhere.\npackage ignored\n",
+		"ignored/ignored.go":     "package ignored\n",
+		"partial/partial.go":     "package partial\n",
+		"partial/legacy.go":      "//porto:ignore\n\npackage partial\n",
+		"ignored/nested/nest.go": "package nested\n",
+	})
+
+	r := &recordingReporter{}
+	c, err := FindAndAddVanityImportForDir(dir, dir+"/ignored", Options{ListDiffFiles: true, Explain: true, Reporter: r})
+	require.NoError(t, err)
+	assert.Equal(t, 1, c)
+	require.Len(t, r.events, 3)
+	assert.Equal(t, Event{Path: "ignored/doc.go", ModulePath: "example.com/repo/ignored", Action: ActionSkipped, SkipReason: SkipIgnoreDirective}, r.events[0])
+	assert.Equal(t, Event{Path: "ignored/ignored.go", ModulePath: "example.com/repo/ignored", Action: ActionSkipped, SkipReason: SkipIgnoreDirective}, r.events[1])
+	assert.Equal(t, "ignored/nested/nest.go", r.events[2].Path, "subpackages are not opted out")
+	assert.Equal(t, ActionListed, r.events[2].Action)
+
+	r = &recordingReporter{}
+	c, err = FindAndAddVanityImportForDir(dir, dir+"/partial", Options{ListDiffFiles: true, Explain: true, Reporter: r})
+	require.NoError(t, err)
+	assert.Equal(t, 1, c)
+	require.Len(t, r.events, 2)
+	assert.Equal(t, "partial/legacy.go", r.events[0].Path)
+	assert.Equal(t, SkipIgnoreDirective, r.events[0].SkipReason)
+	assert.Equal(t, "partial/partial.go", r.events[1].Path)
+	assert.Equal(t, ActionListed, r.events[1].Action)
+
+	for name, opts := range map[string]Options{
+		"skipped":        {SkipFilesRegexes: []*regexp.Regexp{regexp.MustCompile(`doc\.go`)}},
+		"not restricted": {RestrictToFilesRegexes: []*regexp.Regexp{regexp.MustCompile(`^(ignored|nest)\.go$`)}},
+	} {
+		t.Run("doc.go "+name, func(t *testing.T) {
+			r := &recordingReporter{}
+			opts.ListDiffFiles, opts.Reporter = true, r
+			c, err := FindAndAddVanityImportForDir(dir, dir+"/ignored", opts)
+			require.NoError(t, err)
+			assert.Equal(t, 1, c)
+			require.Len(t, r.events, 1)
+			assert.Equal(t, "ignored/nested/nest.go", r.events[0].Path)
+		})
+	}
+
+	newContent, err := FindAndAddVanityImportForContent(dir+"/ignored/new.go", []byte("package ignored\n"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "package ignored\n", string(newContent))
+
+	newContent, err = FindAndAddVanityImportForContent(dir+"/partial/new.go", []byte("package partial\n"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "package partial // import \"example.com/repo/partial\"\n", string(newContent))
+}
+
+func TestFindAndAddVanityImportForChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example.com/repo\n",
+		"a/doc.go":        "package a\n",
+		"a/a.go":          "package a\n",
+		"a/a_test.go":     "package a\n",
+		"b/b.go":          "package b\n",
+		"c/sub/sub.go":    "package sub\n",
+		"c/sub/helper.go": "package sub\n",
+	}
+	writeFiles(t, dir, files)
+
+	changedFiles := []string{dir + "/a/a.go", dir + "/a/a_test.go", dir + "/c/sub/sub.go"}
+
+	t.Run("only changed files are processed", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, Explain: true, ChangedFiles: changedFiles, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, c)
+		require.Len(t, r.events, 3)
+		assert.Equal(t, "a/a_test.go", r.events[0].Path)
+		assert.Equal(t, SkipTestFile, r.events[0].SkipReason)
+		assert.Equal(t, "a/a.go", r.events[1].Path)
+		assert.Equal(t, ActionListed, r.events[1].Action)
+		assert.Equal(t, "c/sub/sub.go", r.events[2].Path)
+		assert.Equal(t, "example.com/repo/c/sub", r.events[2].ModulePath)
+	})
+
+	t.Run("unchanged files are still part of the package", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, SingleFilePerPackage: true, ChangedFiles: changedFiles, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "c/sub/sub.go", r.events[0].Path)
+	})
+
+	t.Run("no changed files", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir, Options{ListDiffFiles: true, Explain: true, ChangedFiles: []string{}, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, c)
+		assert.Empty(t, r.events)
+	})
+}
+
+func TestVanityImportPath(t *testing.T) {
+	opts := Options{VanityPaths: map[string]string{
+		"github.com/org/x":     "go.org.dev/x",
+		"github.com/org/x/sub": "go.sub.dev",
+	}}
+
+	assert.Equal(t, "go.org.dev/x", opts.vanityImportPath("github.com/org/x"))
+	assert.Equal(t, "go.org.dev/x/pkg", opts.vanityImportPath("github.com/org/x/pkg"))
+	assert.Equal(t, "go.sub.dev/pkg", opts.vanityImportPath("github.com/org/x/sub/pkg"))
+	assert.Equal(t, "github.com/org/xy", opts.vanityImportPath("github.com/org/xy"))
+	assert.Equal(t, "github.com/org/y", Options{}.vanityImportPath("github.com/org/y"))
+}
+
+func TestFindAndAddVanityImportWithVanityPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":             "module github.com/org/x\n",
+		"x.go":               "package x // import \"github.com/org/x\"\n",
+		"pkg/pkg.go":         "package pkg // import \"go.org.dev/x/pkg\"\n",
+		"other/go.mod":       "module github.com/org/other\n",
+		"other/o.go":         "package other\n",
+		"vanity/go.mod":      "module github.com/org/vanity\n",
+		"vanity/v.go":        "package vanity\n",
+		"vanity/.porto.yaml": "vanity-paths:\n  github.com/org/vanity: go.vanity.dev\n",
+	})
+
+	r := &recordingReporter{}
+	c, err := FindAndAddVanityImportForDir(dir, dir, Options{
+		ListDiffFiles:  true,
+		UseConfigFiles: true,
+		VanityPaths:    map[string]string{"github.com/org/x": "go.org.dev/x"},
+		Reporter:       r,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, c)
+	require.Len(t, r.events, 3)
+	assert.Equal(t, "x.go", r.events[0].Path)
+	assert.Equal(t, "go.org.dev/x", r.events[0].ModulePath)
+	assert.Equal(t, newImportComment("go.org.dev/x"), r.events[0].NewImportComment)
+	assert.Equal(t, "other/o.go", r.events[1].Path)
+	assert.Equal(t, "github.com/org/other", r.events[1].ModulePath)
+	assert.Equal(t, "vanity/v.go", r.events[2].Path)
+	assert.Equal(t, "go.vanity.dev", r.events[2].ModulePath)
+
+	opts := Options{VanityPaths: map[string]string{"github.com/org/x": "go.org.dev/x"}}
+	newContent, err := FindAndAddVanityImportForContent(dir+"/pkg/new.go", []byte("package pkg\n"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "package pkg // import \"go.org.dev/x/pkg\"\n", string(newContent))
+}
+
 func TestMatchesAny(t *testing.T) {
 	assert.True(
 		t,
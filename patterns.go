@@ -0,0 +1,179 @@
+package porto
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patternTarget is the directory matched by a package pattern.
+type patternTarget struct {
+	absDir string
+	// Directory and import path of the module the directory belongs to, empty if none
+	moduleDir, moduleName string
+	// Whether the subdirectories are matched too
+	recursive bool
+	// Whether the pattern is a plain directory, walked as FindAndAddVanityImportForDir does
+	isDir bool
+}
+
+// covers tells whether the packages matched by t include the ones matched by other.
+func (t patternTarget) covers(other patternTarget) bool {
+	if t.absDir == other.absDir {
+		return t.recursive || !other.recursive
+	}
+	return t.recursive && strings.HasPrefix(other.absDir, t.absDir+pathSeparator)
+}
+
+// isPathPattern tells whether a pattern refers to a directory rather than to an
+// import path. On top of the rules of the go command, patterns naming an existing
+// directory are considered paths as porto always took directories as argument.
+func isPathPattern(workingDir, pattern string) bool {
+	if filepath.IsAbs(pattern) || pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") {
+		return true
+	}
+
+	fi, err := os.Stat(filepath.Join(workingDir, pattern))
+	return err == nil && fi.IsDir()
+}
+
+// resolvePattern resolves a package pattern the way the go command does. Directories
+// and import paths match a single package unless ending with /..., import paths being
+// resolved against the module of the working dir. When walkDirs is set, directories
+// without /... are walked recursively as FindAndAddVanityImportForDir does.
+func resolvePattern(workingDir, pattern string, walkDirs bool) (patternTarget, error) {
+	base, recursive := pattern, false
+	if strings.HasSuffix(pattern, "/...") {
+		base, recursive = strings.TrimSuffix(pattern, "/..."), true
+	}
+	if strings.Contains(base, "...") {
+		return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: wildcards are only supported as a trailing /...", pattern)
+	}
+
+	t := patternTarget{recursive: recursive}
+	isPath := isPathPattern(workingDir, base)
+	if isPath {
+		t.absDir = filepath.FromSlash(base)
+		if !filepath.IsAbs(t.absDir) {
+			t.absDir = filepath.Join(workingDir, t.absDir)
+		}
+		if walkDirs && !recursive {
+			t.isDir, t.recursive = true, true
+		}
+	} else {
+		moduleDir, moduleName, ok := Options{}.findOwningModule(workingDir)
+		if !ok {
+			return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: the working dir does not belong to a module", pattern)
+		}
+		if base != moduleName && !strings.HasPrefix(base, moduleName+"/") {
+			return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: it is not part of the module %q", pattern, moduleName)
+		}
+
+		t.absDir = filepath.Join(moduleDir, filepath.FromSlash(strings.TrimPrefix(base, moduleName)))
+	}
+
+	fi, err := os.Stat(t.absDir)
+	if err != nil {
+		return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: %v", pattern, err)
+	}
+	if !fi.IsDir() {
+		return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: %q is not a directory", pattern, t.absDir)
+	}
+
+	if t.isDir {
+		return t, nil
+	}
+
+	t.moduleDir, t.moduleName, _ = Options{}.importPathOf(t.absDir)
+	switch {
+	case isPath && !recursive && t.moduleName == "":
+		return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: the directory does not belong to a module", pattern)
+	case !isPath && !recursive && t.moduleName != base:
+		// the directory belongs to a nested module hence the import path does not exist
+		return patternTarget{}, fmt.Errorf("failed to resolve pattern %q: it is not part of the module of the working dir", pattern)
+	}
+
+	return t, nil
+}
+
+// FindAndAddVanityImportForPatterns adds the vanity import to the packages matched by
+// the given patterns, e.g. ./pkg/... or github.com/owner/repo/cmd/foo, resolving their
+// import path based on the module they belong to. As for the go command, patterns ending
+// with /... do not match the nested modules. A single directory, or every directory in
+// workspace mode, is walked recursively as FindAndAddVanityImportForDir does.
+func FindAndAddVanityImportForPatterns(workingDir string, patterns []string, opts Options) (int, error) {
+	// porto always took a directory as argument and kept walking it recursively
+	walkDirs := len(patterns) == 1 || opts.UseWorkspace
+
+	var targets []patternTarget
+	for _, pattern := range patterns {
+		t, err := resolvePattern(workingDir, pattern, walkDirs)
+		if err != nil {
+			return 0, err
+		}
+
+		if opts.UseWorkspace && !t.isDir {
+			return 0, fmt.Errorf("failed to resolve pattern %q: only directories are supported in workspace mode", pattern)
+		}
+
+		// packages matched by several patterns are processed once
+		covered := false
+		kept := targets[:0]
+		for _, prev := range targets {
+			if prev.covers(t) {
+				covered = true
+			}
+			if covered || !t.covers(prev) {
+				kept = append(kept, prev)
+			}
+		}
+		targets = kept
+		if !covered {
+			targets = append(targets, t)
+		}
+	}
+
+	return run(opts, func(opts Options) (int, error) {
+		var (
+			gc   int
+			errs []error
+		)
+		for _, t := range targets {
+			c, err := findAndAddVanityImportForTarget(workingDir, t, opts)
+			if err != nil {
+				if err := opts.handleError(&errs, err); err != nil {
+					return 0, err
+				}
+			}
+
+			gc += c
+		}
+
+		return gc, joinErrors(errs...)
+	})
+}
+
+func findAndAddVanityImportForTarget(workingDir string, t patternTarget, opts Options) (int, error) {
+	if t.isDir || t.moduleName == "" {
+		return findAndAddVanityImportForDir(workingDir, t.absDir, opts)
+	}
+
+	opts, err := opts.withConfigsFor(t.absDir)
+	if err != nil {
+		return 0, err
+	}
+
+	reason, err := moduleDirSkipReason(opts, t.moduleDir, t.absDir)
+	if err != nil {
+		return 0, err
+	}
+	if reason != "" {
+		return 0, explainSkip(opts, workingDir, t.absDir, t.moduleName, reason)
+	}
+
+	opts.nonRecursive = !t.recursive
+	opts.skipNestedModules = true
+	return findAndAddVanityImportForModuleDir(workingDir, t.absDir, t.absDir, t.moduleName, opts)
+}
@@ -0,0 +1,146 @@
+package porto
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePatternsModule(t *testing.T) string {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":             "module example.com/repo\n",
+		"repo.go":            "package repo\n",
+		"pkg/a/a.go":         "package a\n",
+		"pkg/a/sub/sub.go":   "package sub\n",
+		"pkg/b/b.go":         "package b\n",
+		"pkg/nested/go.mod":  "module example.com/pkgnested\n",
+		"pkg/nested/n.go":    "package nested\n",
+		"cmd/foo/foo.go":     "package foo\n",
+		"cmd/foo/sub/sub.go": "package sub\n",
+		"nested/go.mod":      "module example.com/nested\n",
+		"nested/nested.go":   "package nested\n",
+		"outside/lib/lib.go": "package lib\n",
+	})
+	return dir
+}
+
+func TestResolvePattern(t *testing.T) {
+	dir := writePatternsModule(t)
+
+	testCases := []struct {
+		pattern  string
+		walkDirs bool
+		expected patternTarget
+	}{
+		{"./pkg/...", false, patternTarget{absDir: dir + "/pkg", moduleDir: dir, moduleName: "example.com/repo/pkg", recursive: true}},
+		{"./pkg/...", true, patternTarget{absDir: dir + "/pkg", moduleDir: dir, moduleName: "example.com/repo/pkg", recursive: true}},
+		{"./...", false, patternTarget{absDir: dir, moduleDir: dir, moduleName: "example.com/repo", recursive: true}},
+		{"./pkg/a", false, patternTarget{absDir: dir + "/pkg/a", moduleDir: dir, moduleName: "example.com/repo/pkg/a"}},
+		{"pkg/a", false, patternTarget{absDir: dir + "/pkg/a", moduleDir: dir, moduleName: "example.com/repo/pkg/a"}},
+		{"./pkg/a", true, patternTarget{absDir: dir + "/pkg/a", recursive: true, isDir: true}},
+		{"./nested", false, patternTarget{absDir: dir + "/nested", moduleDir: dir + "/nested", moduleName: "example.com/nested"}},
+		{"example.com/repo/cmd/foo", false, patternTarget{absDir: dir + "/cmd/foo", moduleDir: dir, moduleName: "example.com/repo/cmd/foo"}},
+		{"example.com/repo/cmd/foo", true, patternTarget{absDir: dir + "/cmd/foo", moduleDir: dir, moduleName: "example.com/repo/cmd/foo"}},
+		{"example.com/repo", false, patternTarget{absDir: dir, moduleDir: dir, moduleName: "example.com/repo"}},
+		{"example.com/repo/pkg/...", false, patternTarget{absDir: dir + "/pkg", moduleDir: dir, moduleName: "example.com/repo/pkg", recursive: true}},
+		{"./nested/...", false, patternTarget{absDir: dir + "/nested", moduleDir: dir + "/nested", moduleName: "example.com/nested", recursive: true}},
+		{"./outside/...", false, patternTarget{absDir: dir + "/outside", moduleDir: dir, moduleName: "example.com/repo/outside", recursive: true}},
+	}
+	for _, tCase := range testCases {
+		t.Run(fmt.Sprintf("%s walking dirs %t", tCase.pattern, tCase.walkDirs), func(t *testing.T) {
+			target, err := resolvePattern(dir, tCase.pattern, tCase.walkDirs)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, target)
+		})
+	}
+
+	_, err := resolvePattern(dir, "./pkg/.../sub", false)
+	assert.EqualError(t, err, "failed to resolve pattern \"./pkg/.../sub\": wildcards are only supported as a trailing /...")
+
+	_, err = resolvePattern(dir, "example.com/other/pkg", false)
+	assert.EqualError(t, err, "failed to resolve pattern \"example.com/other/pkg\": it is not part of the module \"example.com/repo\"")
+
+	_, err = resolvePattern(dir, "example.com/repo/nested", false)
+	assert.EqualError(t, err, "failed to resolve pattern \"example.com/repo/nested\": it is not part of the module of the working dir")
+
+	_, err = resolvePattern(dir, "./missing/...", false)
+	assert.Error(t, err)
+}
+
+func TestFindAndAddVanityImportForPatterns(t *testing.T) {
+	dir := writePatternsModule(t)
+
+	paths := func(events []Event) []string {
+		var paths []string
+		for _, e := range events {
+			paths = append(paths, e.Path)
+		}
+		return paths
+	}
+
+	t.Run("single package and recursive pattern", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForPatterns(dir, []string{"./pkg/...", "example.com/repo/cmd/foo"}, Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, c)
+		assert.Equal(t, []string{"pkg/a/a.go", "pkg/a/sub/sub.go", "pkg/b/b.go", "cmd/foo/foo.go"}, paths(r.events))
+		assert.Equal(t, "example.com/repo/pkg/a/sub", r.events[1].ModulePath)
+	})
+
+	t.Run("directories match a single package", func(t *testing.T) {
+		for _, patterns := range [][]string{{"./cmd/foo", "./pkg/b"}, {"example.com/repo/cmd/foo", "example.com/repo/pkg/b"}} {
+			r := &recordingReporter{}
+			c, err := FindAndAddVanityImportForPatterns(dir, patterns, Options{ListDiffFiles: true, Reporter: r})
+
+			require.NoError(t, err)
+			assert.Equal(t, 2, c)
+			assert.Equal(t, []string{"cmd/foo/foo.go", "pkg/b/b.go"}, paths(r.events))
+		}
+	})
+
+	t.Run("a single directory is walked recursively", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForPatterns(dir, []string{"./pkg"}, Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, c)
+		assert.Equal(t, []string{"pkg/a/a.go", "pkg/a/sub/sub.go", "pkg/b/b.go", "pkg/nested/n.go"}, paths(r.events))
+	})
+
+	t.Run("recursive patterns stop at nested modules", func(t *testing.T) {
+		r := &recordingReporter{}
+		_, err := FindAndAddVanityImportForPatterns(dir, []string{"./pkg/..."}, Options{ListDiffFiles: true, Explain: true, Reporter: r})
+
+		require.NoError(t, err)
+		require.Len(t, r.events, 4)
+		assert.Equal(t, "pkg/nested", r.events[3].Path)
+		assert.Equal(t, SkipNestedModule, r.events[3].SkipReason)
+	})
+
+	t.Run("overlapping patterns are processed once", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForPatterns(dir, []string{"example.com/repo/pkg/a", "./pkg/a/...", "./pkg/a/sub/..."}, Options{ListDiffFiles: true, Reporter: r})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, c)
+		assert.Equal(t, []string{"pkg/a/a.go", "pkg/a/sub/sub.go"}, paths(r.events))
+	})
+
+	t.Run("concurrently", func(t *testing.T) {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForPatterns(dir, []string{"./pkg/...", "example.com/repo/cmd/foo"}, Options{ListDiffFiles: true, Reporter: r, Concurrency: 4})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, c)
+		assert.Equal(t, []string{"pkg/a/a.go", "pkg/a/sub/sub.go", "pkg/b/b.go", "cmd/foo/foo.go"}, paths(r.events))
+	})
+
+	t.Run("patterns in workspace mode", func(t *testing.T) {
+		_, err := FindAndAddVanityImportForPatterns(dir, []string{"./pkg/..."}, Options{UseWorkspace: true})
+		assert.EqualError(t, err, "failed to resolve pattern \"./pkg/...\": only directories are supported in workspace mode")
+	})
+}
@@ -0,0 +1,134 @@
+package porto
+
+import (
+	"fmt"
+	"io"
+)
+
+// Action describes what porto did with a file whose vanity import differs.
+type Action string
+
+const (
+	// ActionWritten means the new content was written to the file.
+	ActionWritten Action = "written"
+	// ActionListed means the file was listed as having a wrong vanity import.
+	ActionListed Action = "listed"
+	// ActionDiffed means a diff between the original and the new content was rendered.
+	ActionDiffed Action = "diffed"
+	// ActionPreviewed means the new content was rendered without being written.
+	ActionPreviewed Action = "previewed"
+	// ActionUnchanged means the file already has the right vanity import.
+	ActionUnchanged Action = "unchanged"
+	// ActionSkipped means the file or directory was not evaluated, see SkipReason.
+	ActionSkipped Action = "skipped"
+)
+
+// isFinding tells whether the action was taken over a file whose vanity import differs.
+func (a Action) isFinding() bool {
+	return a != ActionUnchanged && a != ActionSkipped
+}
+
+// SkipReason describes the rule that caused a file or directory to be skipped.
+type SkipReason string
+
+const (
+	// SkipMainPackage means the file belongs to a main package, which can't be imported.
+	SkipMainPackage SkipReason = "main package"
+	// SkipGenerated means the file is generated.
+	SkipGenerated SkipReason = "generated file"
+	// SkipTestFile means the file is a test file.
+	SkipTestFile SkipReason = "test file"
+	// SkipIgnoreDirective means the file, or its package through doc.go, opted out
+	// with the //porto:ignore directive.
+	SkipIgnoreDirective SkipReason = "opted out with //porto:ignore"
+	// SkipBuildConstraints means the file is excluded by build constraints or its name suffix.
+	SkipBuildConstraints SkipReason = "excluded by build constraints"
+	// SkipIgnoredFile means the file is only built when passing the ignore tag.
+	SkipIgnoredFile SkipReason = "only built with the ignore tag"
+	// SkipMultiplePackages means the directory of the file holds more than one package.
+	SkipMultiplePackages SkipReason = "directory holds multiple packages"
+	// SkipNotCanonicalFile means the vanity import belongs to another file of the package.
+	SkipNotCanonicalFile SkipReason = "not the canonical file of the package"
+	// SkipMatchesSkipFiles means the file matches the regexes of files to be skipped.
+	SkipMatchesSkipFiles SkipReason = "matches skip files regexes"
+	// SkipNotInRestrictedFiles means the file does not match the regexes of files to be included.
+	SkipNotInRestrictedFiles SkipReason = "does not match restrict to files regexes"
+	// SkipUnexportedDir means the directory is either testdata or internal.
+	SkipUnexportedDir SkipReason = "unexported directory"
+	// SkipInternalModule means the import path of the directory is internal.
+	SkipInternalModule SkipReason = "internal import path"
+	// SkipNestedModule means the directory holds a module not listed in the workspace,
+	// not matched by a package pattern or processed on its own.
+	SkipNestedModule SkipReason = "nested module"
+	// SkipMatchesSkipDirs means the directory matches the regexes of directories to be skipped.
+	SkipMatchesSkipDirs SkipReason = "matches skip dirs regexes"
+	// SkipNotInRestrictedDirs means the directory does not match the regexes of dirs to be included.
+	SkipNotInRestrictedDirs SkipReason = "does not match restrict to dirs regexes"
+)
+
+// Event represents the result of processing a single file.
+type Event struct {
+	// Path of the file relative to the working dir
+	Path string
+	// Line and column of the package clause
+	Line, Column int
+	// Import path of the package the file belongs to
+	ModulePath string
+	// Import comment found in the file, empty if there was none
+	OldImportComment string
+	// Import comment porto expects in the file, empty if it is being removed
+	NewImportComment string
+	// Whether the import comment found differs from the ones in other files of
+	// the same package
+	Conflicting bool
+	// Action taken over the file
+	Action Action
+	// Rule that caused the file or directory to be skipped
+	SkipReason SkipReason
+	// Content of the file before adding or removing the vanity import
+	OriginalContent []byte
+	// Content of the file after adding or removing the vanity import
+	Content []byte
+}
+
+// Reporter receives an event for every file whose vanity import differs from
+// porto's.
+type Reporter interface {
+	Report(Event) error
+}
+
+type textReporter struct {
+	w io.Writer
+}
+
+// NewTextReporter returns a reporter that prints the events in a human readable
+// format, listing the files or dumping their new content depending on the action.
+func NewTextReporter(w io.Writer) Reporter {
+	return &textReporter{w: w}
+}
+
+func (r *textReporter) Report(e Event) error {
+	var err error
+	switch e.Action {
+	case ActionListed:
+		if e.Conflicting || isStyleFinding(e) {
+			_, err = fmt.Fprintf(r.w, "%s: %s\n", e.Path, findingMessage(e))
+		} else if e.NewImportComment == "" {
+			_, err = fmt.Fprintf(r.w, "%s: unexpected vanity import\n", e.Path)
+		} else {
+			_, err = fmt.Fprintf(r.w, "%s: missing right vanity import\n", e.Path)
+		}
+	case ActionDiffed:
+		var diff string
+		if diff, err = unifiedDiff(e.Path, e.OriginalContent, e.Content); err == nil {
+			_, err = io.WriteString(r.w, diff)
+		}
+	case ActionUnchanged:
+		_, err = fmt.Fprintf(r.w, "%s: unchanged\n", e.Path)
+	case ActionSkipped:
+		_, err = fmt.Fprintf(r.w, "%s: skipped, %s\n", e.Path, e.SkipReason)
+	case ActionPreviewed:
+		_, err = fmt.Fprintf(r.w, "👉 %s\n\n%s\n", e.Path, e.Content)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package porto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextReporter(t *testing.T) {
+	t.Run("listed", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{Path: "a/b.go", ModulePath: "a/b", NewImportComment: `// import "a/b"`, Action: ActionListed}))
+		assert.Equal(t, "a/b.go: missing right vanity import\n", buf.String())
+	})
+
+	t.Run("listed for its style", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{
+			Path:             "a/b.go",
+			ModulePath:       "a/b",
+			OldImportComment: `// import "a/b"`,
+			NewImportComment: `/* import "a/b" */`,
+			Action:           ActionListed,
+		}))
+		assert.Equal(t, "a/b.go: vanity import \"a/b\" not written as expected\n", buf.String())
+	})
+
+	t.Run("listed as conflicting", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{
+			Path:             "a/b.go",
+			ModulePath:       "a/b",
+			OldImportComment: `// import "a/c"`,
+			NewImportComment: `// import "a/b"`,
+			Conflicting:      true,
+			Action:           ActionListed,
+		}))
+		assert.Equal(t, "a/b.go: conflicting vanity import \"a/c\" in package, expected \"a/b\"\n", buf.String())
+	})
+
+	t.Run("listed for removal", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{Path: "a/b.go", OldImportComment: `// import "a/b"`, Action: ActionListed}))
+		assert.Equal(t, "a/b.go: unexpected vanity import\n", buf.String())
+	})
+
+	t.Run("previewed", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{
+			Path:    "a/b.go",
+			Action:  ActionPreviewed,
+			Content: []byte("package b // import \"a/b\"\n"),
+		}))
+		assert.Equal(t, "👉 a/b.go\n\npackage b // import \"a/b\"\n\n", buf.String())
+	})
+
+	t.Run("diffed", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{
+			Path:            "a/b.go",
+			Action:          ActionDiffed,
+			OriginalContent: []byte("package b\n"),
+			Content:         []byte("package b // import \"a/b\"\n"),
+		}))
+		assert.Equal(t, "--- a/a/b.go\n+++ b/a/b.go\n@@ -1 +1 @@\n-package b\n+package b // import \"a/b\"\n", buf.String())
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{Path: "a/b.go", Action: ActionSkipped, SkipReason: SkipGenerated}))
+		assert.Equal(t, "a/b.go: skipped, generated file\n", buf.String())
+	})
+
+	t.Run("written", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require.NoError(t, NewTextReporter(buf).Report(Event{Path: "a/b.go", Action: ActionWritten}))
+		assert.Empty(t, buf.String())
+	})
+}
@@ -0,0 +1,126 @@
+package porto
+
+import "sync"
+
+// segment holds the events reported for a part of the walk so they can be
+// forwarded in the same order as a sequential walk would report them.
+type segment struct {
+	events []Event
+	count  int
+	err    error
+	// done is closed once the segment is complete, nil for the segments
+	// holding the events reported synchronously by the walk.
+	done chan struct{}
+}
+
+func (s *segment) Report(e Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+// scheduler evaluates the files of each directory concurrently with a bounded
+// number of workers while the walk goes on. It acts as the reporter of the walk
+// and keeps the events in the order they were scheduled. Unless keeping going,
+// the work scheduled after a failed one is not run, as a sequential walk would
+// have stopped there.
+type scheduler struct {
+	workers   chan struct{}
+	keepGoing bool
+	wg        sync.WaitGroup
+	segments  []*segment
+
+	mu sync.Mutex
+	// failedAt is the index of the first failed segment, -1 if none
+	failedAt int
+}
+
+func newScheduler(workers int, keepGoing bool) *scheduler {
+	return &scheduler{workers: make(chan struct{}, workers), keepGoing: keepGoing, failedAt: -1}
+}
+
+// stopped tells whether the segment with the given index is not to be run as one
+// scheduled before it failed.
+func (s *scheduler) stopped(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.keepGoing && s.failedAt != -1 && s.failedAt < i
+}
+
+// fail records the failure of the segment with the given index.
+func (s *scheduler) fail(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failedAt == -1 || i < s.failedAt {
+		s.failedAt = i
+	}
+}
+
+// Report records an event reported synchronously by the walk.
+func (s *scheduler) Report(e Event) error {
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].done != nil {
+		s.segments = append(s.segments, &segment{})
+	}
+	return s.segments[len(s.segments)-1].Report(e)
+}
+
+// schedule runs fn in a worker, recording the events it reports in its own segment.
+// Nothing is scheduled once some work failed unless keeping going.
+func (s *scheduler) schedule(opts Options, fn func(Options) (int, error)) {
+	i := len(s.segments)
+	if s.stopped(i) {
+		return
+	}
+
+	seg := &segment{done: make(chan struct{})}
+	s.segments = append(s.segments, seg)
+
+	opts.Reporter = seg
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(seg.done)
+
+		s.workers <- struct{}{}
+		defer func() { <-s.workers }()
+
+		if s.stopped(i) {
+			return
+		}
+		if seg.count, seg.err = fn(opts); seg.err != nil {
+			s.fail(i)
+		}
+	}()
+}
+
+// flush waits for all the scheduled work and forwards the events to the given
+// reporter in order. It returns the number of files changed by the scheduled work
+// and all the errors found along the way, or only the first one unless keeping going.
+func (s *scheduler) flush(r Reporter) (int, error) {
+	defer s.wg.Wait()
+
+	var (
+		gc   int
+		errs []error
+	)
+	for _, seg := range s.segments {
+		if seg.done != nil {
+			<-seg.done
+		}
+
+		for _, e := range seg.events {
+			if err := r.Report(e); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		gc += seg.count
+		if seg.err != nil {
+			if !s.keepGoing {
+				return gc, seg.err
+			}
+			errs = append(errs, seg.err)
+		}
+	}
+
+	return gc, joinErrors(errs...)
+}
@@ -0,0 +1,85 @@
+package porto
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentWalkKeepsOrder(t *testing.T) {
+	cwd, _ := os.Getwd()
+
+	sequential := &recordingReporter{}
+	sc, err := FindAndAddVanityImportForDir(cwd, cwd+"/testdata", Options{
+		ListDiffFiles: true,
+		Explain:       true,
+		Reporter:      sequential,
+	})
+	require.NoError(t, err)
+
+	concurrent := &recordingReporter{}
+	cc, err := FindAndAddVanityImportForDir(cwd, cwd+"/testdata", Options{
+		ListDiffFiles: true,
+		Explain:       true,
+		Reporter:      concurrent,
+		Concurrency:   4,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, sc, cc)
+	assert.Equal(t, sequential.events, concurrent.events)
+}
+
+func TestConcurrentWalkAggregatesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":           "module pad\n",
+		"left/left.go":     "package left\nfunc {\n",
+		"middle/middle.go": "package middle\n",
+		"right/right.go":   "package right\nfunc {\n",
+	})
+
+	r := &recordingReporter{}
+	c, err := FindAndAddVanityImportForDir(dir, dir, Options{
+		ListDiffFiles: true,
+		Reporter:      r,
+		Concurrency:   2,
+		KeepGoing:     true,
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), dir+"/left/left.go")
+	assert.Contains(t, err.Error(), dir+"/right/right.go")
+	assert.Equal(t, 1, c)
+	require.Len(t, r.events, 1)
+	assert.Equal(t, "middle/middle.go", r.events[0].Path)
+}
+
+func TestConcurrentWalkStopsAtTheFirstError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":           "module pad\n",
+		"a/a.go":           "package a\n",
+		"left/left.go":     "package left\nfunc {\n",
+		"middle/middle.go": "package middle\n",
+		"right/right.go":   "package right\nfunc {\n",
+	})
+
+	for _, concurrency := range []int{0, 4} {
+		r := &recordingReporter{}
+		c, err := FindAndAddVanityImportForDir(dir, dir, Options{
+			ListDiffFiles: true,
+			Reporter:      r,
+			Concurrency:   concurrency,
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), dir+"/left/left.go")
+		assert.NotContains(t, err.Error(), dir+"/right/right.go")
+		assert.Equal(t, 0, c)
+		require.Len(t, r.events, 1)
+		assert.Equal(t, "a/a.go", r.events[0].Path)
+	}
+}